@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// adminKeyHeader carries the key for admin-only features.
+const adminKeyHeader = "X-Admin-Key"
+
+// adminKeys are the API keys allowed to use admin features, loaded from the
+// comma-separated ADMIN_API_KEYS. With none configured every check fails.
+type adminKeys [][]byte
+
+func loadAdminKeys() adminKeys {
+	var keys adminKeys
+	for _, k := range strings.Split(os.Getenv("ADMIN_API_KEYS"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, []byte(k))
+		}
+	}
+	return keys
+}
+
+// authorized reports whether r presents a configured admin key.
+func (k adminKeys) authorized(r *http.Request) bool {
+	presented := []byte(r.Header.Get(adminKeyHeader))
+	if len(presented) == 0 {
+		return false
+	}
+	ok := false
+	for _, key := range k {
+		if subtle.ConstantTimeCompare(presented, key) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// require rejects requests without a valid admin key.
+func (k adminKeys) require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !k.authorized(r) {
+			writeError(r.Context(), w, http.StatusUnauthorized, "admin key required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"codigo/internal/storage"
+)
+
+// capacityHeadroom is how much spare throughput must remain after taking
+// workers out, relative to the current enqueue rate.
+const capacityHeadroom = 1.2
+
+type capacityReport struct {
+	LiveWorkers         int     `json:"live_workers"`
+	Unavailable         int     `json:"unavailable"`
+	RemainingWorkers    int     `json:"remaining_workers"`
+	Window              string  `json:"window"`
+	EnqueueRate         float64 `json:"enqueue_rate_per_sec"`
+	AvgJobSeconds       float64 `json:"avg_job_seconds"`
+	CapacityPerWorker   float64 `json:"capacity_per_worker_per_sec"`
+	RemainingCapacity   float64 `json:"remaining_capacity_per_sec"`
+	Backlog             int64   `json:"backlog"`
+	BacklogDrainSeconds float64 `json:"backlog_drain_seconds,omitempty"`
+	Safe                bool    `json:"safe"`
+	Reason              string  `json:"reason,omitempty"`
+}
+
+// restartCapacity answers whether the fleet can sustain the current enqueue
+// rate with ?unavailable=N workers restarting. Per-worker capacity is
+// estimated as concurrency / average attempt duration over ?window= (default
+// 5m). With ?enforce=true an unsafe plan is answered with 409 so drain
+// scripts can stop before creating a backlog.
+func (s *Server) restartCapacity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	unavailable := 1
+	if v := q.Get("unavailable"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(ctx, w, http.StatusBadRequest, "unavailable must be a non-negative integer")
+			return
+		}
+		unavailable = n
+	}
+	window := 5 * time.Minute
+	if v := q.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			writeError(ctx, w, http.StatusBadRequest, "window must be a duration of at least 1m")
+			return
+		}
+		window = d
+	}
+
+	workers := s.workers.live()
+	report := capacityReport{
+		LiveWorkers: len(workers),
+		Unavailable: unavailable,
+		Window:      window.String(),
+	}
+	report.RemainingWorkers = max(report.LiveWorkers-unavailable, 0)
+
+	var concurrency int
+	for _, ws := range workers {
+		concurrency += max(ws.Concurrency, 1)
+	}
+
+	qctx, cancel := storage.WithQuery(ctx, "restart_capacity")
+	defer cancel()
+	var enqueued int64
+	var avgJobSeconds *float64
+	err := s.bgdb.QueryRow(qctx, `
+		SELECT
+			(SELECT count(*) FROM jobs WHERE created_at > now() - $1 * interval '1 second'),
+			(SELECT count(*) FROM jobs WHERE status = 'queued'),
+			(SELECT avg(extract(epoch FROM finished_at - started_at))::float8
+			 FROM job_attempts WHERE finished_at > now() - $1 * interval '1 second')`,
+		window.Seconds()).Scan(&enqueued, &report.Backlog, &avgJobSeconds)
+	if err != nil {
+		s.logger.Error("database error - restart capacity", zap.Error(err))
+		writeError(ctx, w, http.StatusInternalServerError, "db error")
+		return
+	}
+	report.EnqueueRate = float64(enqueued) / window.Seconds()
+
+	switch {
+	case report.LiveWorkers == 0:
+		report.Reason = "no live workers"
+	case avgJobSeconds == nil || *avgJobSeconds <= 0:
+		report.Reason = "no completed attempts in window to estimate capacity"
+	default:
+		report.AvgJobSeconds = *avgJobSeconds
+		report.CapacityPerWorker = float64(concurrency) / float64(report.LiveWorkers) / report.AvgJobSeconds
+		report.RemainingCapacity = report.CapacityPerWorker * float64(report.RemainingWorkers)
+
+		spare := report.RemainingCapacity - report.EnqueueRate
+		if spare > 0 && report.Backlog > 0 {
+			report.BacklogDrainSeconds = float64(report.Backlog) / spare
+		}
+		if report.RemainingCapacity >= report.EnqueueRate*capacityHeadroom {
+			report.Safe = true
+		} else {
+			report.Reason = fmt.Sprintf("remaining capacity %.2f/s is below %.0f%% of enqueue rate %.2f/s",
+				report.RemainingCapacity, capacityHeadroom*100, report.EnqueueRate)
+		}
+	}
+
+	status := http.StatusOK
+	if !report.Safe && q.Get("enforce") == "true" {
+		status = http.StatusConflict
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"codigo/internal/config"
+	"codigo/internal/obs"
+)
+
+const (
+	// clientBucket is the granularity of the rolling client window.
+	clientBucket = 10 * time.Second
+	// maxTrackedClientRoutes bounds the client and route pairs counted per
+	// bucket; pairs beyond it are counted under otherClient.
+	maxTrackedClientRoutes = 10000
+	otherClient            = "other"
+	// topClientRoutes is how many of a client's busiest routes
+	// /admin/top-clients lists.
+	topClientRoutes = 5
+)
+
+// clientRoute is one client calling one route.
+type clientRoute struct {
+	client string
+	route  string
+	method string
+}
+
+type clientCounts struct {
+	requests     int64
+	clientErrors int64 // 4xx
+	serverErrors int64 // 5xx
+}
+
+func (c *clientCounts) add(o clientCounts) {
+	c.requests += o.requests
+	c.clientErrors += o.clientErrors
+	c.serverErrors += o.serverErrors
+}
+
+type clientBucketCounts struct {
+	start  time.Time
+	counts map[clientRoute]*clientCounts
+}
+
+// clientTracker counts requests and errors per client and route over a
+// rolling window, for finding abusive or broken clients during incidents.
+// Clients are identified by X-Tenant-ID, the only client identity the API
+// has. Metrics carry the top clients only, like the tenant dimension.
+type clientTracker struct {
+	service string
+	window  time.Duration
+	labels  *obs.TopKLabel
+
+	mu       sync.Mutex
+	buckets  []clientBucketCounts // oldest first
+	inFlight map[string]int64
+}
+
+func newClientTracker(service string) *clientTracker {
+	window := config.Duration("CLIENT_STATS_WINDOW", 5*time.Minute)
+	if window < clientBucket {
+		window = clientBucket
+	}
+	t := &clientTracker{
+		service:  service,
+		window:   window,
+		labels:   obs.NewTopKLabel(config.Int("CLIENT_METRICS_TOP_K", 20), window),
+		inFlight: make(map[string]int64),
+	}
+	// Clients that drop out of the top K lose their series rather than
+	// keeping them until restart
+	t.labels.OnEvict(func(client string) {
+		obs.DeleteLabel("client", prom.ClientRequests.MetricVec, prom.ClientRequestsInFlight.MetricVec)(client)
+	})
+	return t
+}
+
+// clientOf returns the client a request is attributed to.
+func clientOf(r *http.Request) string {
+	if tenant := r.Header.Get("X-Tenant-ID"); tenant != "" {
+		return tenant
+	}
+	return "default"
+}
+
+// clientRequest is a request start has marked in flight.
+type clientRequest struct {
+	client string
+	// label is the client label its metrics are recorded under.
+	label    string
+	inFlight prometheus.Gauge
+}
+
+// start marks a request of client in flight.
+func (t *clientTracker) start(client string) clientRequest {
+	req := clientRequest{client: client, label: t.labels.Value(client)}
+	t.mu.Lock()
+	t.inFlight[client]++
+	t.mu.Unlock()
+	req.inFlight = prom.ClientRequestsInFlight.WithLabelValues(t.service, req.label)
+	req.inFlight.Inc()
+	return req
+}
+
+// finish records the outcome of a request started with start.
+func (t *clientTracker) finish(req clientRequest, route, method string, code int) {
+	client := req.client
+	result := "ok"
+	var c clientCounts
+	c.requests = 1
+	switch {
+	case code >= 500:
+		result = "server_error"
+		c.serverErrors = 1
+	case code >= 400:
+		result = "client_error"
+		c.clientErrors = 1
+	}
+	// Through the handle, so a client evicted from the top K meanwhile
+	// doesn't come back as a negative series
+	req.inFlight.Dec()
+	prom.ClientRequests.WithLabelValues(t.service, req.label, result).Inc()
+
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inFlight[client]--; t.inFlight[client] <= 0 {
+		delete(t.inFlight, client)
+	}
+	b := t.current(now)
+	key := clientRoute{client: client, route: route, method: method}
+	counts, ok := b.counts[key]
+	if !ok {
+		if len(b.counts) >= maxTrackedClientRoutes {
+			key = clientRoute{client: otherClient, route: otherClient}
+			if counts, ok = b.counts[key]; !ok {
+				counts = &clientCounts{}
+				b.counts[key] = counts
+			}
+		} else {
+			counts = &clientCounts{}
+			b.counts[key] = counts
+		}
+	}
+	counts.add(c)
+}
+
+// current returns the bucket for now, opening it and dropping buckets that
+// left the window. t.mu must be held.
+func (t *clientTracker) current(now time.Time) *clientBucketCounts {
+	start := now.Truncate(clientBucket)
+	if n := len(t.buckets); n > 0 && t.buckets[n-1].start.Equal(start) {
+		return &t.buckets[n-1]
+	}
+	cutoff := now.Add(-t.window)
+	kept := t.buckets[:0]
+	for _, b := range t.buckets {
+		if b.start.Add(clientBucket).After(cutoff) {
+			kept = append(kept, b)
+		}
+	}
+	t.buckets = append(kept, clientBucketCounts{start: start, counts: make(map[clientRoute]*clientCounts)})
+	return &t.buckets[len(t.buckets)-1]
+}
+
+type routeStats struct {
+	Route    string `json:"route"`
+	Method   string `json:"method"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+type clientStats struct {
+	Client       string       `json:"client"`
+	Requests     int64        `json:"requests"`
+	RatePerSec   float64      `json:"rate_per_second"`
+	ClientErrors int64        `json:"client_errors"`
+	ServerErrors int64        `json:"server_errors"`
+	ErrorRate    float64      `json:"error_rate"`
+	InFlight     int64        `json:"in_flight"`
+	Routes       []routeStats `json:"routes"`
+}
+
+type topClientsResponse struct {
+	WindowSeconds float64       `json:"window_seconds"`
+	SortedBy      string        `json:"sorted_by"`
+	Clients       []clientStats `json:"clients"`
+}
+
+// top returns the n clients with the most requests, or the most errors with
+// byErrors, over the window.
+func (t *clientTracker) top(n int, byErrors bool) []clientStats {
+	now := time.Now()
+	cutoff := now.Add(-t.window)
+	type clientAgg struct {
+		clientCounts
+		routes map[clientRoute]*clientCounts
+	}
+	aggs := make(map[string]*clientAgg)
+
+	t.mu.Lock()
+	for _, b := range t.buckets {
+		if !b.start.Add(clientBucket).After(cutoff) {
+			continue
+		}
+		for key, c := range b.counts {
+			agg, ok := aggs[key.client]
+			if !ok {
+				agg = &clientAgg{routes: make(map[clientRoute]*clientCounts)}
+				aggs[key.client] = agg
+			}
+			agg.add(*c)
+			rc, ok := agg.routes[key]
+			if !ok {
+				rc = &clientCounts{}
+				agg.routes[key] = rc
+			}
+			rc.add(*c)
+		}
+	}
+	inFlight := make(map[string]int64, len(t.inFlight))
+	for client, v := range t.inFlight {
+		inFlight[client] = v
+		if _, ok := aggs[client]; !ok {
+			aggs[client] = &clientAgg{routes: make(map[clientRoute]*clientCounts)}
+		}
+	}
+	t.mu.Unlock()
+
+	stats := make([]clientStats, 0, len(aggs))
+	for client, agg := range aggs {
+		cs := clientStats{
+			Client:       client,
+			Requests:     agg.requests,
+			RatePerSec:   float64(agg.requests) / t.window.Seconds(),
+			ClientErrors: agg.clientErrors,
+			ServerErrors: agg.serverErrors,
+			InFlight:     inFlight[client],
+			Routes:       []routeStats{},
+		}
+		if agg.requests > 0 {
+			cs.ErrorRate = float64(agg.clientErrors+agg.serverErrors) / float64(agg.requests)
+		}
+		for key, c := range agg.routes {
+			cs.Routes = append(cs.Routes, routeStats{Route: key.route, Method: key.method, Requests: c.requests, Errors: c.clientErrors + c.serverErrors})
+		}
+		sort.Slice(cs.Routes, func(i, j int) bool { return cs.Routes[i].Requests > cs.Routes[j].Requests })
+		if len(cs.Routes) > topClientRoutes {
+			cs.Routes = cs.Routes[:topClientRoutes]
+		}
+		stats = append(stats, cs)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if byErrors {
+			ei, ej := stats[i].ClientErrors+stats[i].ServerErrors, stats[j].ClientErrors+stats[j].ServerErrors
+			if ei != ej {
+				return ei > ej
+			}
+		}
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].Client < stats[j].Client
+	})
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// topClients serves the busiest clients of the rolling window with their
+// error rates and busiest routes. ?n= sets how many (default 10, at most
+// 100) and ?sort=errors ranks by errors instead of requests.
+func (s *Server) topClients(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	n := 10
+	if v := r.URL.Query().Get("n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 100 {
+			writeError(ctx, w, http.StatusBadRequest, "n must be between 1 and 100")
+			return
+		}
+		n = parsed
+	}
+	sortBy := r.URL.Query().Get("sort")
+	switch sortBy {
+	case "":
+		sortBy = "requests"
+	case "requests", "errors":
+	default:
+		writeError(ctx, w, http.StatusBadRequest, "sort must be requests or errors")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(topClientsResponse{
+		WindowSeconds: s.clients.window.Seconds(),
+		SortedBy:      sortBy,
+		Clients:       s.clients.top(n, sortBy == "errors"),
+	})
+}
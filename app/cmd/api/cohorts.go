@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"codigo/internal/storage"
+)
+
+// jobCohortWindows are the creation-age cohorts the cohort exporter reports.
+var jobCohortWindows = []struct {
+	label string
+	age   time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+}
+
+// runCohortExporter periodically recomputes job success rate and end-to-end
+// latency percentiles from the jobs table for jobs created in each cohort
+// window. Unlike the worker counters these survive worker restarts and
+// counter resets, since the database is the source of truth.
+func (s *Server) runCohortExporter(serviceName string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, w := range jobCohortWindows {
+			if err := s.exportJobCohort(context.Background(), serviceName, w.label, w.age); err != nil {
+				s.logger.Error("job cohort export failed", zap.String("window", w.label), zap.Error(err))
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func (s *Server) exportJobCohort(ctx context.Context, serviceName, window string, age time.Duration) error {
+	qctx, cancel := storage.WithQuery(ctx, "job_cohort_stats")
+	defer cancel()
+
+	// Latency runs from creation to the end of the last attempt, so it
+	// includes queueing and retries. percentile_cont skips jobs without
+	// attempts and yields NULL when there are none.
+	var done, failed, expired int64
+	var p50, p95, p99 *float64
+	err := s.bgdb.QueryRow(qctx, `
+		SELECT
+			count(*) FILTER (WHERE j.status = 'done'),
+			count(*) FILTER (WHERE j.status = 'failed'),
+			count(*) FILTER (WHERE j.status = 'expired'),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY extract(epoch FROM a.finished_at - j.created_at)) FILTER (WHERE j.status = 'done'),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY extract(epoch FROM a.finished_at - j.created_at)) FILTER (WHERE j.status = 'done'),
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY extract(epoch FROM a.finished_at - j.created_at)) FILTER (WHERE j.status = 'done')
+		FROM jobs j
+		LEFT JOIN LATERAL (
+			SELECT max(finished_at) AS finished_at FROM job_attempts WHERE job_id = j.id
+		) a ON true
+		WHERE j.created_at >= now() - $1 * interval '1 second'
+			AND j.status IN ('done', 'failed', 'expired')`,
+		age.Seconds()).Scan(&done, &failed, &expired, &p50, &p95, &p99)
+	if err != nil {
+		return err
+	}
+
+	prom.JobCohortJobs.WithLabelValues(serviceName, window, "done").Set(float64(done))
+	prom.JobCohortJobs.WithLabelValues(serviceName, window, "failed").Set(float64(failed))
+	prom.JobCohortJobs.WithLabelValues(serviceName, window, "expired").Set(float64(expired))
+
+	// An empty cohort has no success rate; drop the series rather than
+	// report 0 or 1 for it.
+	if total := done + failed + expired; total > 0 {
+		prom.JobCohortSuccessRate.WithLabelValues(serviceName, window).Set(float64(done) / float64(total))
+	} else {
+		prom.JobCohortSuccessRate.DeleteLabelValues(serviceName, window)
+	}
+	for quantile, v := range map[string]*float64{"0.5": p50, "0.95": p95, "0.99": p99} {
+		if v != nil {
+			prom.JobCohortLatency.WithLabelValues(serviceName, window, quantile).Set(*v)
+		} else {
+			prom.JobCohortLatency.DeleteLabelValues(serviceName, window, quantile)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"codigo/internal/storage"
+)
+
+const costRollupBatchSize = 5000
+
+// rollupCostsSQL adds the next batch of attempts past the watermark $1 to
+// job_costs and returns the new watermark. Attempts recorded in the last $2
+// seconds are left for a later run, and so is everything after the first of
+// them, so a recording transaction still in flight, as long as it takes
+// less than $2, can't commit an attempt below a watermark that has moved
+// past it.
+const rollupCostsSQL = `
+	WITH batch AS (
+		SELECT id, tenant, type, finished_at, wall_seconds, cpu_seconds
+		FROM job_attempts
+		WHERE id > $1 AND id < coalesce(
+			(SELECT min(id) FROM job_attempts WHERE recorded_at >= now() - $2 * interval '1 second'),
+			9223372036854775807)
+		ORDER BY id
+		LIMIT $3
+	), rolled AS (
+		INSERT INTO job_costs (tenant, type, day, jobs, wall_seconds, cpu_seconds)
+		SELECT tenant, type, (finished_at AT TIME ZONE 'UTC')::date, count(*),
+			coalesce(sum(wall_seconds), 0), coalesce(sum(cpu_seconds), 0)
+		FROM batch
+		WHERE tenant IS NOT NULL AND type IS NOT NULL
+		GROUP BY 1, 2, 3
+		ON CONFLICT (tenant, day, type) DO UPDATE SET
+			jobs = job_costs.jobs + excluded.jobs,
+			wall_seconds = job_costs.wall_seconds + excluded.wall_seconds,
+			cpu_seconds = job_costs.cpu_seconds + excluded.cpu_seconds
+	)
+	SELECT coalesce(max(id), $1), count(*) FROM batch`
+
+// runCostRollup periodically folds newly recorded job attempts into the
+// per-tenant, per-type, per-day totals of job_costs, which /v1/stats/costs
+// reads instead of aggregating the attempts on every request. Each attempt
+// is counted once: a watermark row in job_costs_rollup is locked while a
+// batch is added, so replicas running it concurrently take turns.
+func (s *Server) runCostRollup(lag, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rolled, err := s.rollupCosts(context.Background(), lag)
+		if err != nil {
+			s.logger.Error("job cost rollup failed", zap.Int64("attempts", rolled), zap.Error(err))
+		} else if rolled > 0 {
+			s.logger.Info("rolled up job costs", zap.Int64("attempts", rolled))
+		}
+	}
+}
+
+func (s *Server) rollupCosts(ctx context.Context, lag time.Duration) (int64, error) {
+	var total int64
+	for {
+		var rolled int64
+		err := s.WithBackgroundTx(ctx, func(tx pgx.Tx) error {
+			qctx, cancel := storage.WithQuery(ctx, "rollup_job_costs")
+			defer cancel()
+			var watermark int64
+			if err := tx.QueryRow(qctx, `SELECT rolled_up_to FROM job_costs_rollup FOR UPDATE`).Scan(&watermark); err != nil {
+				return err
+			}
+			if err := tx.QueryRow(qctx, rollupCostsSQL, watermark, lag.Seconds(), costRollupBatchSize).Scan(&watermark, &rolled); err != nil {
+				return err
+			}
+			_, err := tx.Exec(qctx, `UPDATE job_costs_rollup SET rolled_up_to = $1, rolled_up_at = now()`, watermark)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+		total += rolled
+		if rolled < costRollupBatchSize {
+			return total, nil
+		}
+	}
+}
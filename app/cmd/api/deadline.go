@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// requestDeadlineHeader lets clients bound job creation with an absolute
+	// RFC 3339 deadline.
+	requestDeadlineHeader = "X-Request-Deadline"
+
+	// grpcTimeoutHeader is the relative alternative, in gRPC's format: up to
+	// eight digits and a unit of H, M, S, m (ms), u (µs) or n (ns).
+	grpcTimeoutHeader = "Grpc-Timeout"
+)
+
+var errDeadlinePassed = errors.New("request deadline already passed")
+
+// requestDeadline returns the deadline the client set on r, or the zero
+// time when it set none. X-Request-Deadline wins over Grpc-Timeout.
+func requestDeadline(r *http.Request, now time.Time) (time.Time, error) {
+	var deadline time.Time
+	if v := r.Header.Get(requestDeadlineHeader); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, errors.New(requestDeadlineHeader + " must be an RFC 3339 time")
+		}
+		deadline = t
+	} else if v := r.Header.Get(grpcTimeoutHeader); v != "" {
+		d, err := parseGRPCTimeout(v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		deadline = now.Add(d)
+	} else {
+		return time.Time{}, nil
+	}
+	if !deadline.After(now) {
+		return time.Time{}, errDeadlinePassed
+	}
+	return deadline, nil
+}
+
+// parseGRPCTimeout parses a gRPC timeout value such as "250m" or "30S".
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	invalid := errors.New(grpcTimeoutHeader + " must be 1-8 digits followed by H, M, S, m, u or n")
+	if len(v) < 2 || len(v) > 9 {
+		return 0, invalid
+	}
+	// ParseUint, unlike ParseInt, rejects a leading sign.
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 63)
+	if err != nil {
+		return 0, invalid
+	}
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, invalid
+	}
+	// 99999999H is past what a time.Duration holds; like grpc-go, cap it
+	// rather than let it wrap into the past.
+	if n > uint64(math.MaxInt64/unit) {
+		return math.MaxInt64, nil
+	}
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var grpcTimeoutPattern = regexp.MustCompile(`^[0-9]{1,8}[HMSmun]$`)
+
+func FuzzParseGRPCTimeout(f *testing.F) {
+	for _, seed := range []string{"250m", "30S", "1H", "99999999H", "+1S", "-1S", "1", "S", "123456789n"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, v string) {
+		d, err := parseGRPCTimeout(v)
+		if valid := grpcTimeoutPattern.MatchString(v); valid != (err == nil) {
+			t.Fatalf("parseGRPCTimeout(%q) error = %v, want valid %v", v, err, valid)
+		}
+		if err == nil && d < 0 {
+			t.Errorf("parseGRPCTimeout(%q) = %v, want a positive duration", v, d)
+		}
+	})
+}
+
+func FuzzRequestDeadline(f *testing.F) {
+	f.Add("2026-01-01T00:00:00Z", "")
+	f.Add("", "99999999H")
+	f.Add("not a time", "5S")
+	f.Fuzz(func(t *testing.T, absolute, relative string) {
+		r := httptest.NewRequest("POST", "/v1/jobs", nil)
+		r.Header.Set(requestDeadlineHeader, absolute)
+		r.Header.Set(grpcTimeoutHeader, relative)
+		now := time.Unix(1700000000, 0)
+		deadline, err := requestDeadline(r, now)
+		if err == nil && !deadline.IsZero() && !deadline.After(now) {
+			t.Errorf("requestDeadline() = %v, want a deadline after %v", deadline, now)
+		}
+	})
+}
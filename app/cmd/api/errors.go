@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"codigo/internal/errs"
+)
+
+// errorResponse is the JSON body returned for every failed request.
+type errorResponse struct {
+	Error      string `json:"error"`
+	ErrorClass string `json:"error_class,omitempty"`
+	TraceID    string `json:"trace_id,omitempty"`
+	Reference  string `json:"reference"`
+}
+
+// errorClassHeader tells clients how to treat a retryable failure.
+const errorClassHeader = "X-Error-Class"
+
+// errorClassDependency marks failures caused by Postgres or NATS being
+// unavailable; clients should retry after Retry-After.
+const errorClassDependency = "dependency_unavailable"
+
+// errorClassBacklog marks requests refused because the workers already
+// hold as many of the tenant's jobs as they buffer; clients should retry
+// after Retry-After.
+const errorClassBacklog = "backlog_full"
+
+// dependencyRetryAfter is the backoff suggested when Postgres or NATS fail.
+const dependencyRetryAfter = 5 * time.Second
+
+// writeError renders a JSON error carrying the request's trace ID and a short
+// support reference that users can quote in bug reports.
+func writeError(ctx context.Context, w http.ResponseWriter, status int, msg string) {
+	resp := errorResponse{Error: msg, Reference: supportReference(ctx)}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		resp.TraceID = sc.TraceID().String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeRetryableError renders a JSON error like writeError and adds the
+// Retry-After and X-Error-Class headers clients use to back off.
+func writeRetryableError(ctx context.Context, w http.ResponseWriter, status int, class string, retryAfter time.Duration, msg string) {
+	resp := errorResponse{Error: msg, ErrorClass: class, Reference: supportReference(ctx)}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		resp.TraceID = sc.TraceID().String()
+	}
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.Header().Set(errorClassHeader, class)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeDomainError renders err with the status its errs.Kind maps to.
+// Contention and unavailable dependencies are retryable 503s; msg is the
+// message for every kind.
+func writeDomainError(ctx context.Context, w http.ResponseWriter, err error, msg string) {
+	switch errs.KindOf(err) {
+	case errs.NotFound:
+		writeError(ctx, w, http.StatusNotFound, msg)
+	case errs.Conflict:
+		writeError(ctx, w, http.StatusConflict, msg)
+	case errs.Invalid:
+		writeError(ctx, w, http.StatusBadRequest, msg)
+	case errs.Contention, errs.Unavailable:
+		writeRetryableError(ctx, w, http.StatusServiceUnavailable, errorClassDependency, dependencyRetryAfter, msg)
+	default:
+		writeError(ctx, w, http.StatusInternalServerError, msg)
+	}
+}
+
+// supportReference derives a short code from the trace ID so support can find
+// the trace from it; without tracing a random code is returned instead.
+func supportReference(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return "CDG-" + strings.ToUpper(sc.TraceID().String()[:10])
+	}
+	b := make([]byte, 5)
+	rand.Read(b)
+	return "CDG-" + strings.ToUpper(hex.EncodeToString(b))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"codigo/internal/config"
+)
+
+// runHealthcheck probes /healthz on HTTP_ADDR and returns the process exit
+// code. It backs `api healthcheck`, used as an exec probe when the API only
+// listens on a Unix socket that kubelet and Docker can't reach over HTTP.
+func runHealthcheck() int {
+	addr := config.String("HTTP_ADDR", ":8080")
+	client := &http.Client{Timeout: 2 * time.Second}
+	url := "http://localhost/healthz"
+	if path, ok := strings.CutPrefix(addr, config.UnixAddrPrefix); ok {
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+	} else {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid HTTP_ADDR %q: %v\n", addr, err)
+			return 1
+		}
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "localhost"
+		}
+		url = "http://" + net.JoinHostPort(host, port) + "/healthz"
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck failed: %v\n", err)
+		return 1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "healthcheck failed: status %d\n", resp.StatusCode)
+		return 1
+	}
+	return 0
+}
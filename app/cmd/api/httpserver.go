@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"codigo/internal/config"
+)
+
+// newHTTPServer builds the API server with keep-alive and HTTP/2 settings
+// from the environment. Plain-text HTTP/2 (h2c) lets SDK clients multiplex
+// many job creations over a few connections; HTTP/1.1 clients are
+// unaffected.
+func newHTTPServer(service string, h http.Handler) *http.Server {
+	idleTimeout := config.Duration("HTTP_IDLE_TIMEOUT", 120*time.Second)
+	if config.String("HTTP2_ENABLED", "true") == "true" {
+		h = h2c.NewHandler(h, &http2.Server{
+			MaxConcurrentStreams: uint32(config.Int("HTTP2_MAX_CONCURRENT_STREAMS", 250)),
+			IdleTimeout:          idleTimeout,
+		})
+	}
+
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: config.Duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		IdleTimeout:       idleTimeout,
+		ConnState:         trackConnState(service),
+	}
+	srv.SetKeepAlivesEnabled(config.String("HTTP_KEEPALIVES", "true") == "true")
+	return srv
+}
+
+// trackConnState keeps codigo_http_connections in step with connection state
+// changes. Hijacked connections (h2c upgrades) leave the gauge like closed
+// ones do.
+func trackConnState(service string) func(net.Conn, http.ConnState) {
+	var mu sync.Mutex
+	states := make(map[net.Conn]http.ConnState)
+	return func(c net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if prev, ok := states[c]; ok {
+			prom.HTTPConnections.WithLabelValues(service, prev.String()).Dec()
+		}
+		switch state {
+		case http.StateNew:
+			prom.HTTPConnectionsOpened.WithLabelValues(service).Inc()
+			fallthrough
+		case http.StateActive, http.StateIdle:
+			states[c] = state
+			prom.HTTPConnections.WithLabelValues(service, state.String()).Inc()
+		default:
+			delete(states, c)
+		}
+	}
+}
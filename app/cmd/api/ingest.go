@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"codigo/internal/queue"
+)
+
+// ingestSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// optionally prefixed with "sha256=" as GitHub and others send it.
+const ingestSignatureHeader = "X-Signature-256"
+
+// maxIngestBody caps the webhook payload read for signature verification.
+const maxIngestBody = 1 << 20
+
+// ingestMessageOverhead is what the job message adds to the webhook body,
+// headers and encryption included, when checking it fits NATS' max_payload.
+const ingestMessageOverhead = 4 << 10
+
+// ingestSource maps one webhook sender onto jobs.
+type ingestSource struct {
+	secret    []byte
+	tenant    string
+	jobType   string
+	refHeader string // header holding the sender's delivery ID, used as external_ref
+}
+
+// loadIngestSources reads INGEST_SOURCES, a comma-separated list of
+// source=tenant:type[:ref-header] mappings. Each source's secret comes from
+// INGEST_SECRET_<SOURCE>; sources without a secret are not served.
+func loadIngestSources(logger *zap.Logger) map[string]ingestSource {
+	sources := make(map[string]ingestSource)
+	for _, entry := range strings.Split(os.Getenv("INGEST_SOURCES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, mapping, _ := strings.Cut(entry, "=")
+		parts := strings.SplitN(mapping, ":", 3)
+		if !queue.ValidToken(name) || len(parts) < 2 || !queue.ValidToken(parts[0]) || !queue.ValidToken(parts[1]) {
+			logger.Warn("ignoring invalid ingest source", zap.String("entry", entry))
+			continue
+		}
+		secretVar := "INGEST_SECRET_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		secret := os.Getenv(secretVar)
+		if secret == "" {
+			logger.Warn("ignoring ingest source without secret",
+				zap.String("source", name),
+				zap.String("secret_env", secretVar))
+			continue
+		}
+		src := ingestSource{secret: []byte(secret), tenant: parts[0], jobType: parts[1]}
+		if len(parts) == 3 {
+			src.refHeader = parts[2]
+		}
+		sources[name] = src
+	}
+	return sources
+}
+
+// verify checks the body against the signature header in constant time.
+func (src ingestSource) verify(body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, src.secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// ingestWebhook turns a signed third-party webhook into a job whose payload
+// is the webhook body; handlers read it with jobs.Payload. Redelivered
+// webhooks carrying the same delivery ID resolve to the job created first.
+func (s *Server) ingestWebhook(w http.ResponseWriter, r *http.Request) {
+	// Senders retry on 503, so webhooks are delivered after the window
+	if s.refuseDuringMaintenance(w, r) {
+		return
+	}
+	ctx, span := otel.Tracer(s.serviceName).Start(r.Context(), "ingestWebhook")
+	defer span.End()
+	traceID := span.SpanContext().TraceID().String()
+
+	name := chi.URLParam(r, "source")
+	src, ok := s.ingest[name]
+	if !ok {
+		writeError(ctx, w, http.StatusNotFound, "unknown ingest source")
+		return
+	}
+	span.SetAttributes(attribute.String("ingest.source", name))
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBody))
+	if limit := s.nats.MaxPayload(); err == nil && limit > 0 && int64(len(body)) > limit-ingestMessageOverhead {
+		err = errors.New("webhook body doesn't fit in a job message")
+	}
+	if err != nil {
+		prom.WebhooksReceived.WithLabelValues(s.serviceName, name, "invalid").Inc()
+		writeError(ctx, w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+	if !src.verify(body, r.Header.Get(ingestSignatureHeader)) {
+		prom.WebhooksReceived.WithLabelValues(s.serviceName, name, "bad_signature").Inc()
+		s.logger.Warn("webhook signature mismatch",
+			zap.String("trace_id", traceID),
+			zap.String("source", name))
+		writeError(ctx, w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	if len(body) > 0 && !json.Valid(body) {
+		prom.WebhooksReceived.WithLabelValues(s.serviceName, name, "invalid").Inc()
+		writeError(ctx, w, http.StatusBadRequest, "payload must be JSON")
+		return
+	}
+
+	var externalRef string
+	if src.refHeader != "" {
+		if ref := r.Header.Get(src.refHeader); ref != "" {
+			externalRef = name + ":" + ref
+		}
+	}
+	if len(externalRef) > 256 {
+		externalRef = ""
+	}
+
+	id := fmt.Sprintf("job_%d", time.Now().UnixNano())
+	span.SetAttributes(
+		attribute.String("job.id", id),
+		attribute.String("job.tenant", src.tenant),
+		attribute.String("job.type", src.jobType),
+	)
+
+	existingID, err := s.insertJob(ctx, id, src.tenant, src.jobType, externalRef)
+	if err != nil {
+		prom.WebhooksReceived.WithLabelValues(s.serviceName, name, "error").Inc()
+		s.logger.Error("database error - insert webhook job",
+			zap.String("trace_id", traceID),
+			zap.String("source", name),
+			zap.Error(err))
+		span.RecordError(err)
+		writeDomainError(ctx, w, err, "db insert error")
+		return
+	}
+	if existingID != "" {
+		prom.WebhooksReceived.WithLabelValues(s.serviceName, name, "duplicate").Inc()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"job_id": existingID, "existing": true})
+		return
+	}
+
+	if err := s.publishJob(ctx, id, src.tenant, src.jobType, time.Time{}, body); err != nil {
+		prom.WebhooksReceived.WithLabelValues(s.serviceName, name, "error").Inc()
+		s.logger.Error("nats publish error",
+			zap.String("trace_id", traceID),
+			zap.String("job_id", id),
+			zap.Error(err))
+		span.RecordError(err)
+		s.writeDispatchError(ctx, w, s.logger, id, err)
+		return
+	}
+
+	prom.WebhooksReceived.WithLabelValues(s.serviceName, name, "accepted").Inc()
+	s.logger.Info("webhook ingested",
+		zap.String("trace_id", traceID),
+		zap.String("source", name),
+		zap.String("job_id", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"job_id": id})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"codigo/internal/storage"
+)
+
+const archiveBatchSize = 1000
+
+// runJanitor periodically moves terminal jobs older than archiveAfter from the
+// hot jobs table into jobs_history. Replicas can run it concurrently: rows
+// are claimed with SKIP LOCKED.
+func (s *Server) runJanitor(serviceName string, archiveAfter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		moved, err := s.archiveJobs(context.Background(), archiveAfter)
+		if err != nil {
+			s.logger.Error("job archival failed", zap.Int64("archived", moved), zap.Error(err))
+		} else if moved > 0 {
+			s.logger.Info("archived terminal jobs", zap.Int64("archived", moved))
+		}
+		prom.JobsArchived.WithLabelValues(serviceName).Add(float64(moved))
+	}
+}
+
+func (s *Server) archiveJobs(ctx context.Context, archiveAfter time.Duration) (int64, error) {
+	var total int64
+	for {
+		var moved int64
+		err := s.WithBackgroundTx(ctx, func(tx pgx.Tx) error {
+			qctx, cancel := storage.WithQuery(ctx, "archive_jobs")
+			defer cancel()
+			tag, err := tx.Exec(qctx, `
+				WITH moved AS (
+					DELETE FROM jobs WHERE id IN (
+						SELECT id FROM jobs
+						WHERE status IN ('done', 'failed', 'expired') AND created_at < now() - $1 * interval '1 second'
+						ORDER BY created_at
+						LIMIT $2
+						FOR UPDATE SKIP LOCKED
+					)
+					RETURNING id, created_at, status, tenant, type, external_ref
+				)
+				INSERT INTO jobs_history (id, created_at, status, tenant, type, external_ref)
+				SELECT id, created_at, status, tenant, type, external_ref FROM moved
+				ON CONFLICT (id) DO NOTHING`,
+				archiveAfter.Seconds(), archiveBatchSize)
+			moved = tag.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+		total += moved
+		if moved < archiveBatchSize {
+			return total, nil
+		}
+	}
+}
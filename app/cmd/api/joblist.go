@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+const (
+	defaultJobListLimit = 50
+	maxJobListLimit     = 500
+)
+
+// jobListTimeout is the statement timeout of one listing query. A query
+// that needs longer is scanning too much and the caller is asked to narrow
+// the filters.
+var jobListTimeout = config.Duration("JOB_LIST_TIMEOUT", 2*time.Second)
+
+// jobListExactCountMax is the largest total_count ?count=estimate reports
+// exactly. Larger totals come from the planner's row estimate instead of
+// counting every matching row.
+var jobListExactCountMax = config.Int("JOB_LIST_EXACT_COUNT_MAX", 1000)
+
+// Values of ?count=.
+const (
+	jobCountEstimate = "estimate"
+	jobCountExact    = "exact"
+	jobCountNone     = "none"
+)
+
+// jobListStatuses are the statuses ?status= accepts.
+var jobListStatuses = map[string]bool{"queued": true, "done": true, "failed": true, "expired": true}
+
+// errJobListUnfiltered is returned when a listing has nothing to narrow it
+// down besides the tenant. GET /v1/jobs used to create a job, so the
+// message says where creation went for callers that haven't moved yet.
+var errJobListUnfiltered = errors.New("listing jobs needs at least one of status, type, created_after or created_before; jobs are created with POST /v1/jobs")
+
+type jobListItem struct {
+	JobID       string    `json:"job_id"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExternalRef string    `json:"external_ref,omitempty"`
+}
+
+type jobListResponse struct {
+	Jobs []jobListItem `json:"jobs"`
+	// TotalCount is the number of jobs matching the filters across all
+	// pages, omitted with ?count=none. TotalCountEstimated is set when it
+	// is the planner's estimate rather than an exact count.
+	TotalCount          *int64 `json:"total_count,omitempty"`
+	TotalCountEstimated bool   `json:"total_count_estimated,omitempty"`
+	HasMore             bool   `json:"has_more"`
+	// NextCursor is passed as ?cursor= for the next page; it and the next
+	// link are omitted on the last one.
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Links      jobListLinks `json:"links"`
+}
+
+type jobListLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+}
+
+// jobListPage is one page of a listing, with up to limit+1 jobs, the extra
+// one telling whether another page follows.
+type jobListPage struct {
+	items     []jobListItem
+	total     *int64
+	estimated bool
+}
+
+// jobListFilter is a parsed listing request.
+type jobListFilter struct {
+	statuses      []string
+	jobType       string
+	createdAfter  time.Time
+	createdBefore time.Time
+	limit         int
+	archived      bool // list jobs_history instead of the hot table
+	count         string
+	ascending     bool // oldest first
+
+	// Keyset position: list jobs strictly after this one in the listing
+	// order.
+	afterCreated time.Time
+	afterID      string
+}
+
+// parseJobListFilter reads ?status= (comma-separated), ?type=,
+// ?created_after=, ?created_before= (RFC 3339), ?archived=, ?count=,
+// ?sort=, ?order=, ?limit= and ?cursor=.
+func parseJobListFilter(r *http.Request) (jobListFilter, error) {
+	q := r.URL.Query()
+	f := jobListFilter{limit: defaultJobListLimit, count: jobCountEstimate}
+
+	if v := q.Get("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			status = strings.TrimSpace(status)
+			if !jobListStatuses[status] {
+				return f, errors.New("status must be one or more of queued, done, failed, expired")
+			}
+			f.statuses = append(f.statuses, status)
+		}
+	}
+	if v := q.Get("type"); v != "" {
+		if !queue.ValidToken(v) {
+			return f, errors.New("type must be 1-64 characters of [A-Za-z0-9_-]")
+		}
+		f.jobType = v
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"created_after", &f.createdAfter}, {"created_before", &f.createdBefore}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be an RFC 3339 timestamp", p.name)
+			}
+			*p.dst = t
+		}
+	}
+	if !f.createdAfter.IsZero() && !f.createdBefore.IsZero() && !f.createdAfter.Before(f.createdBefore) {
+		return f, errors.New("created_after must be before created_before")
+	}
+	if v := q.Get("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, errors.New("archived must be true or false")
+		}
+		f.archived = archived
+	}
+	// Jobs have no updated_at or priority column, so created_at is the
+	// only sort key; the order gives the newest-first and oldest-first
+	// views.
+	switch v := q.Get("sort"); v {
+	case "", "created_at":
+	case "updated_at", "priority":
+		return f, fmt.Errorf("sort by %s is not supported; jobs are sorted by created_at", v)
+	default:
+		return f, errors.New("sort must be created_at")
+	}
+	switch v := q.Get("order"); v {
+	case "", "desc":
+	case "asc":
+		f.ascending = true
+	default:
+		return f, errors.New("order must be asc or desc")
+	}
+	switch v := q.Get("count"); v {
+	case "":
+	case jobCountEstimate, jobCountExact, jobCountNone:
+		f.count = v
+	default:
+		return f, errors.New("count must be exact, estimate or none")
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxJobListLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", maxJobListLimit)
+		}
+		f.limit = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		var err error
+		f.afterCreated, f.afterID, err = decodeJobCursor(v)
+		if err != nil {
+			return f, errors.New("invalid cursor")
+		}
+	}
+	return f, nil
+}
+
+// selective reports whether the filter narrows the listing beyond the
+// tenant.
+func (f jobListFilter) selective() bool {
+	return len(f.statuses) > 0 || f.jobType != "" || !f.createdAfter.IsZero() || !f.createdBefore.IsZero()
+}
+
+// encodeJobCursor and decodeJobCursor turn a listing position into the
+// opaque ?cursor= value and back.
+func encodeJobCursor(createdAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+func decodeJobCursor(cursor string) (time.Time, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	ts, id, ok := strings.Cut(string(b), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	return t, id, err
+}
+
+// listJobs pages through the caller's jobs, newest first unless
+// ?order=asc. It lists the
+// hot table, or with ?archived=true the jobs the janitor has moved to
+// jobs_history; the two are listed separately. Listings without a
+// selective filter are refused, and a listing that runs past
+// JOB_LIST_TIMEOUT is cut off, both with 422, so a dashboard can't scan
+// the whole table by accident.
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := s.scopeFrom(ctx)
+	if legacyJobCreation(r) {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(ctx, w, http.StatusMethodNotAllowed, "jobs are created with POST /v1/jobs; GET /v1/jobs lists them")
+		return
+	}
+	loc, err := displayLocation(r)
+	if err != nil {
+		writeError(ctx, w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f, err := parseJobListFilter(r)
+	if err != nil {
+		writeError(ctx, w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !f.selective() {
+		writeError(ctx, w, http.StatusUnprocessableEntity, errJobListUnfiltered.Error())
+		return
+	}
+
+	page, err := s.queryJobList(ctx, scope.Tenant, f)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "57014" { // query_canceled by statement_timeout
+		writeError(ctx, w, http.StatusUnprocessableEntity, "listing took too long; narrow it with status, type or a shorter created_after/created_before range")
+		return
+	}
+	if err != nil {
+		scope.Logger.Error("database error - list jobs", zap.Error(err))
+		writeDomainError(ctx, w, err, "db error")
+		return
+	}
+
+	resp := jobListResponse{
+		Jobs:                page.items,
+		TotalCount:          page.total,
+		TotalCountEstimated: page.estimated,
+		Links:               jobListLinks{Self: r.URL.RequestURI()},
+	}
+	if len(page.items) > f.limit {
+		last := page.items[f.limit-1]
+		resp.Jobs = page.items[:f.limit]
+		resp.HasMore = true
+		resp.NextCursor = encodeJobCursor(last.CreatedAt, last.JobID)
+		resp.Links.Next = jobListLink(r.URL, resp.NextCursor)
+	}
+	for i := range resp.Jobs {
+		resp.Jobs[i].CreatedAt = resp.Jobs[i].CreatedAt.In(loc)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// legacyJobCreation reports whether r carries creation-only parameters,
+// i.e. comes from a client still creating jobs with GET. Those get a 405
+// pointing at POST rather than a listing they can't parse.
+func legacyJobCreation(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Has("external_ref") || q.Has("if_absent")
+}
+
+// jobListLink is the listing at u moved to cursor, keeping the other
+// parameters.
+func jobListLink(u *url.URL, cursor string) string {
+	q := u.Query()
+	q.Set("cursor", cursor)
+	next := url.URL{Path: u.Path, RawQuery: q.Encode()}
+	return next.RequestURI()
+}
+
+// queryJobList returns a page of up to f.limit+1 jobs and, unless ?count=
+// is none, the total matching the filters. Both queries run in one
+// transaction on the interactive pool, which serves user-facing requests,
+// under jobListTimeout.
+func (s *Server) queryJobList(ctx context.Context, tenant string, f jobListFilter) (jobListPage, error) {
+	where := []string{"tenant = $1"}
+	args := []any{tenant}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if len(f.statuses) > 0 {
+		where = append(where, "status = ANY("+arg(f.statuses)+")")
+	}
+	if f.jobType != "" {
+		where = append(where, "type = "+arg(f.jobType))
+	}
+	if !f.createdAfter.IsZero() {
+		where = append(where, "created_at >= "+arg(f.createdAfter))
+	}
+	if !f.createdBefore.IsZero() {
+		where = append(where, "created_at < "+arg(f.createdBefore))
+	}
+	table := "jobs"
+	if f.archived {
+		table = "jobs_history"
+	}
+	// The total covers every page, so it is counted before the cursor
+	// narrows the filters.
+	countFrom := ` FROM ` + table + ` WHERE ` + strings.Join(where, " AND ")
+	countArgs := args[:len(args):len(args)]
+	cmp, order := "<", "DESC"
+	if f.ascending {
+		cmp, order = ">", "ASC"
+	}
+	if f.afterID != "" {
+		where = append(where, "(created_at, id) "+cmp+" ("+arg(f.afterCreated)+", "+arg(f.afterID)+")")
+	}
+	sql := `SELECT id, coalesce(type, ''), status, created_at, coalesce(external_ref, '') FROM ` + table + `
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY created_at ` + order + `, id ` + order + `
+		LIMIT ` + arg(f.limit+1)
+
+	var page jobListPage
+	err := s.WithTx(ctx, func(tx pgx.Tx) error {
+		qctx, cancel := storage.WithQuery(ctx, "list_jobs")
+		defer cancel()
+		if _, err := tx.Exec(qctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", jobListTimeout.Milliseconds())); err != nil {
+			return err
+		}
+		rows, err := tx.Query(qctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		page.items, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (jobListItem, error) {
+			var it jobListItem
+			err := row.Scan(&it.JobID, &it.Type, &it.Status, &it.CreatedAt, &it.ExternalRef)
+			return it, err
+		})
+		if err != nil || f.count == jobCountNone {
+			return err
+		}
+		page.total, page.estimated, err = countJobList(qctx, tx, f.count, countFrom, countArgs)
+		return err
+	})
+	if page.items == nil {
+		page.items = []jobListItem{}
+	}
+	return page, err
+}
+
+// countJobList counts the rows of from, the FROM and WHERE of a listing.
+// In estimate mode only the first jobListExactCountMax+1 rows are counted;
+// past that the planner's estimate is returned, so a large total costs a
+// plan rather than a scan.
+func countJobList(ctx context.Context, tx pgx.Tx, mode, from string, args []any) (*int64, bool, error) {
+	var n int64
+	if mode == jobCountExact {
+		err := tx.QueryRow(ctx, `SELECT count(*)`+from, args...).Scan(&n)
+		return &n, false, err
+	}
+	exactMax := int64(jobListExactCountMax)
+	limit := "$" + strconv.Itoa(len(args)+1)
+	err := tx.QueryRow(ctx, `SELECT count(*) FROM (SELECT 1`+from+` LIMIT `+limit+`) c`, append(args, exactMax+1)...).Scan(&n)
+	if err != nil || n <= exactMax {
+		return &n, false, err
+	}
+
+	var plan []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	var raw []byte
+	if err := tx.QueryRow(ctx, `EXPLAIN (FORMAT JSON) SELECT 1`+from, args...).Scan(&raw); err != nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return nil, false, fmt.Errorf("parse count estimate: %w", err)
+	}
+	if len(plan) == 0 {
+		return nil, false, errors.New("parse count estimate: empty plan")
+	}
+	// The estimate can be off either way; it is at least what was counted.
+	n = max(n, int64(plan[0].Plan.Rows))
+	return &n, true, nil
+}
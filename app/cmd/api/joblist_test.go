@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func FuzzParseJobListFilter(f *testing.F) {
+	for _, seed := range []string{
+		"status=queued,failed&order=asc",
+		"type=report&limit=500&count=exact",
+		"created_after=2026-01-01T00:00:00Z&created_before=2026-02-01T00:00:00.5+01:00",
+		"archived=true&sort=created_at&cursor=" + encodeJobCursor(time.Unix(1700000000, 5), "job_1"),
+		"status=%20done%20&limit=0&cursor=%7C",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, query string) {
+		r := httptest.NewRequest("GET", "/v1/jobs", nil)
+		r.URL.RawQuery = query
+		filter, err := parseJobListFilter(r)
+		if err != nil {
+			return
+		}
+		if filter.limit < 1 || filter.limit > maxJobListLimit {
+			t.Errorf("limit %d outside 1..%d", filter.limit, maxJobListLimit)
+		}
+		for _, status := range filter.statuses {
+			if !jobListStatuses[status] {
+				t.Errorf("unknown status %q accepted", status)
+			}
+		}
+		if !filter.afterCreated.IsZero() && filter.afterID == "" {
+			t.Error("cursor without a job ID accepted")
+		}
+	})
+}
+
+func FuzzJobCursor(f *testing.F) {
+	f.Add(int64(1700000000), int64(123456789), "job_1")
+	f.Add(int64(-62135596800), int64(0), "a|b")
+	f.Fuzz(func(t *testing.T, sec, nsec int64, id string) {
+		createdAt := time.Unix(sec, nsec%1e9).UTC()
+		if id == "" || createdAt.Year() < 0 || createdAt.Year() > 9999 {
+			return
+		}
+		gotCreated, gotID, err := decodeJobCursor(encodeJobCursor(createdAt, id))
+		if err != nil {
+			t.Fatalf("decodeJobCursor(encodeJobCursor(%v, %q)) error = %v", createdAt, id, err)
+		}
+		if !gotCreated.Equal(createdAt) || gotID != id {
+			t.Errorf("cursor round trip = %v, %q, want %v, %q", gotCreated, gotID, createdAt, id)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"codigo/internal/errs"
+	"codigo/internal/storage"
+)
+
+// attemptLogs is one processing attempt of a job and what its handler
+// logged, as JSON lines.
+type attemptLogs struct {
+	Attempt    int       `json:"attempt"`
+	Worker     string    `json:"worker"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Logs       string    `json:"logs"`
+}
+
+type jobLogsResponse struct {
+	JobID    string        `json:"job_id"`
+	Attempts []attemptLogs `json:"attempts"`
+}
+
+// jobLogs serves the logs each attempt of a job captured from its handler,
+// oldest attempt first, so a single job can be debugged without searching
+// the workers' aggregate logs. ?attempt= narrows the response to one
+// attempt.
+func (s *Server) jobLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	scope := s.scopeFrom(ctx)
+	loc, err := displayLocation(r)
+	if err != nil {
+		writeError(ctx, w, http.StatusBadRequest, err.Error())
+		return
+	}
+	attempt := 0
+	if v := r.URL.Query().Get("attempt"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(ctx, w, http.StatusBadRequest, "attempt must be a positive integer")
+			return
+		}
+		attempt = n
+	}
+
+	// The status lookup scopes the job to the caller's tenant.
+	if _, err := s.jobStatus(ctx, id, scope.Tenant); err != nil {
+		if errs.Is(err, errs.NotFound) {
+			writeError(ctx, w, http.StatusNotFound, "job not found")
+			return
+		}
+		scope.Logger.Error("database error - job status",
+			zap.String("job_id", id),
+			zap.Error(err))
+		writeDomainError(ctx, w, err, "db error")
+		return
+	}
+	attempts, err := s.attemptLogs(ctx, id, attempt)
+	if err != nil {
+		scope.Logger.Error("database error - job logs",
+			zap.String("job_id", id),
+			zap.Error(err))
+		writeDomainError(ctx, w, err, "db error")
+		return
+	}
+	if attempt > 0 && len(attempts) == 0 {
+		writeError(ctx, w, http.StatusNotFound, "attempt not found")
+		return
+	}
+	for i := range attempts {
+		attempts[i].StartedAt = attempts[i].StartedAt.In(loc)
+		attempts[i].FinishedAt = attempts[i].FinishedAt.In(loc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobLogsResponse{JobID: id, Attempts: attempts})
+}
+
+// attemptLogs returns the attempts of job id with their logs, or only
+// attempt when it is not zero.
+func (s *Server) attemptLogs(ctx context.Context, id string, attempt int) ([]attemptLogs, error) {
+	qctx, cancel := storage.WithQuery(ctx, "job_logs")
+	defer cancel()
+	rows, err := s.db.Query(qctx, `
+		SELECT attempt, worker, coalesce(trace_id, ''), status, started_at, finished_at, coalesce(logs, '')
+		FROM job_attempts
+		WHERE job_id = $1 AND ($2 = 0 OR attempt = $2)
+		ORDER BY attempt`, id, attempt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []attemptLogs{}
+	for rows.Next() {
+		var a attemptLogs
+		if err := rows.Scan(&a.Attempt, &a.Worker, &a.TraceID, &a.Status, &a.StartedAt, &a.FinishedAt, &a.Logs); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"codigo/internal/errs"
+	"codigo/internal/storage"
+)
+
+type jobStatusResponse struct {
+	JobID     string    `json:"job_id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// StartedAt and CompletedAt are omitted until they happen, as in
+	// jobWaitResponse.
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// AttemptCount is len(Attempts), for clients that only need how many
+	// times the job ran.
+	AttemptCount int          `json:"attempt_count"`
+	Attempts     []jobAttempt `json:"attempts"`
+}
+
+// jobAttempt is one processing attempt of a job, as recorded by the worker
+// that ran it. Its logs are served separately at /v1/jobs/{id}/logs.
+type jobAttempt struct {
+	Attempt    int       `json:"attempt"`
+	Worker     string    `json:"worker"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// getJob returns the caller's job status, timeline and attempts without
+// waiting, from the hot table or the archive. Jobs of other tenants are
+// reported as missing.
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	scope := s.scopeFrom(ctx)
+	loc, err := displayLocation(r)
+	if err != nil {
+		writeError(ctx, w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := s.jobStatus(ctx, id, scope.Tenant)
+	if errs.Is(err, errs.NotFound) {
+		writeError(ctx, w, http.StatusNotFound, "job not found")
+		return
+	}
+	if err != nil {
+		scope.Logger.Error("database error - job status",
+			zap.String("job_id", id),
+			zap.Error(err))
+		writeDomainError(ctx, w, err, "db error")
+		return
+	}
+	attempts, err := s.jobAttempts(ctx, id)
+	if err != nil {
+		scope.Logger.Error("database error - job attempts",
+			zap.String("job_id", id),
+			zap.Error(err))
+		writeDomainError(ctx, w, err, "db error")
+		return
+	}
+	for i := range attempts {
+		attempts[i].StartedAt = attempts[i].StartedAt.In(loc)
+		attempts[i].FinishedAt = attempts[i].FinishedAt.In(loc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobStatusResponse{
+		JobID:        id,
+		Status:       job.Status,
+		CreatedAt:    job.CreatedAt.In(loc),
+		StartedAt:    inLocation(job.StartedAt, loc),
+		CompletedAt:  inLocation(job.CompletedAt, loc),
+		AttemptCount: len(attempts),
+		Attempts:     attempts,
+	})
+}
+
+// jobAttempts returns the recorded attempts of job id, oldest first.
+func (s *Server) jobAttempts(ctx context.Context, id string) ([]jobAttempt, error) {
+	qctx, cancel := storage.WithQuery(ctx, "job_attempts")
+	defer cancel()
+	rows, err := s.db.Query(qctx, `
+		SELECT attempt, worker, coalesce(trace_id, ''), status, coalesce(error, ''), started_at, finished_at
+		FROM job_attempts
+		WHERE job_id = $1
+		ORDER BY attempt`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []jobAttempt{}
+	for rows.Next() {
+		var a jobAttempt
+		if err := rows.Scan(&a.Attempt, &a.Worker, &a.TraceID, &a.Status, &a.Error, &a.StartedAt, &a.FinishedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
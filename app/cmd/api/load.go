@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// loadScoreHeader is added to readiness responses so load balancers that
+// only probe /readyz can still weight traffic by load.
+const loadScoreHeader = "X-Load-Score"
+
+// loadScore rates how busy this replica is from 0 (idle) to 100 (saturated),
+// taking the worse of database pool saturation and the backlog of worker
+// results waiting to be recorded. Jobs don't run here; workers report their
+// own score from their job queues.
+func (s *Server) loadScore() int {
+	stat := s.db.Stat()
+	load := 0.0
+	if max := stat.MaxConns(); max > 0 {
+		load = float64(stat.AcquiredConns()) / float64(max)
+	}
+	if s.results != nil {
+		pending, _, err := s.results.Pending()
+		limit, _, _ := s.results.PendingLimits()
+		if err == nil && limit > 0 {
+			load = math.Max(load, float64(pending)/float64(limit))
+		}
+	}
+	return int(math.Round(100 * math.Min(load, 1)))
+}
+
+// loadWeight reports the load score and the matching traffic weight
+// (100 - load) for weighted load balancing.
+func (s *Server) loadWeight(w http.ResponseWriter, r *http.Request) {
+	load := s.loadScore()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(loadScoreHeader, strconv.Itoa(load))
+	json.NewEncoder(w).Encode(map[string]int{"load": load, "weight": 100 - load})
+}
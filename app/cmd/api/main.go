@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/contract"
+	"codigo/internal/jobs"
+	"codigo/internal/metrics"
+	"codigo/internal/obs"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+// prom holds every metric the API exports. The telemetry module creates it
+// on the binary's registry before anything records.
+var prom *metrics.API
+
+type Server struct {
+	serviceName string
+
+	// db serves user-facing requests; bgdb serves maintenance and reporting
+	// queries so they can't starve it.
+	db      *pgxpool.Pool
+	bgdb    *pgxpool.Pool
+	nats    *nats.Conn
+	logger  *zap.Logger
+	region  string
+	admin   adminKeys
+	workers *workerRegistry
+	ingest  map[string]ingestSource
+	results *nats.Subscription
+
+	maintenance *maintenanceMode
+	clients     *clientTracker
+	// slo is the embedded SLO evaluator; nil without SLO_PROMETHEUS_URL.
+	slo *sloEvaluator
+
+	payloadKeys *queue.Keyring
+	completed   *jobs.CompletionStream
+
+	// warm is set once warmup finishes; readiness fails until then.
+	warm atomic.Bool
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(runHealthcheck())
+	}
+
+	fx.New(
+		fx.WithLogger(obs.FxLogger),
+		fx.StopTimeout(config.Duration("SHUTDOWN_TIMEOUT", 15*time.Second)),
+		configModule,
+		loggingModule,
+		telemetryModule,
+		storageModule,
+		queueModule,
+		serverModule,
+		httpModule,
+	).Run()
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 500*time.Millisecond)
+	defer cancel()
+
+	span := trace.SpanFromContext(ctx)
+	traceID := span.SpanContext().TraceID().String()
+
+	if !s.warm.Load() {
+		writeRetryableError(ctx, w, http.StatusServiceUnavailable, errorClassDependency, dependencyRetryAfter, "warming up")
+		return
+	}
+	if err := s.db.Ping(ctx); err != nil {
+		s.logger.Warn("readiness check failed - database",
+			zap.String("trace_id", traceID),
+			zap.Error(err))
+		writeRetryableError(ctx, w, http.StatusServiceUnavailable, errorClassDependency, dependencyRetryAfter, "db not ready")
+		return
+	}
+	if !s.nats.IsConnected() {
+		s.logger.Warn("readiness check failed - nats",
+			zap.String("trace_id", traceID))
+		writeRetryableError(ctx, w, http.StatusServiceUnavailable, errorClassDependency, dependencyRetryAfter, "nats not ready")
+		return
+	}
+	w.Header().Set(loadScoreHeader, strconv.Itoa(s.loadScore()))
+	w.WriteHeader(200)
+	w.Write([]byte("ready"))
+}
+
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request) {
+	if s.refuseDuringMaintenance(w, r) {
+		return
+	}
+	ctx := r.Context()
+	tr := otel.Tracer("codigo-api")
+	ctx, span := tr.Start(ctx, "createJob")
+	defer span.End()
+
+	scope := s.scopeFrom(ctx)
+	logger := scope.Logger.With(zap.String("span_id", span.SpanContext().SpanID().String()))
+
+	tenant := scope.Tenant
+	jobType := r.URL.Query().Get("type")
+	if jobType == "" {
+		jobType = queue.DefaultToken
+	}
+	if !queue.ValidToken(tenant) || !queue.ValidToken(jobType) {
+		writeError(ctx, w, http.StatusBadRequest, "tenant and type must be 1-64 characters of [A-Za-z0-9_-]")
+		return
+	}
+	// external_ref is unique per tenant. With if_absent=true a repeated ref
+	// returns the existing job instead of failing, so upstream retries don't
+	// enqueue duplicate work.
+	externalRef := r.URL.Query().Get("external_ref")
+	ifAbsent := r.URL.Query().Get("if_absent") == "true"
+	if len(externalRef) > 256 {
+		writeError(ctx, w, http.StatusBadRequest, "external_ref must be at most 256 characters")
+		return
+	}
+
+	// A client deadline bounds the insert and publish here and travels with
+	// the job so workers can drop it once nobody is waiting for it.
+	deadline := scope.Deadline
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+		span.SetAttributes(attribute.String("job.deadline", deadline.UTC().Format(time.RFC3339Nano)))
+	}
+
+	id := fmt.Sprintf("job_%d", time.Now().UnixNano())
+	span.SetAttributes(
+		attribute.String("job.id", id),
+		attribute.String("job.tenant", tenant),
+		attribute.String("job.type", jobType),
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", r.URL.Path),
+	)
+
+	logger.Info("creating job",
+		zap.String("job_id", id),
+		zap.String("type", jobType))
+
+	// Insert job
+	existingID, err := s.insertJob(ctx, id, tenant, jobType, externalRef)
+	if err != nil {
+		logger.Error("database error - insert job",
+			zap.String("job_id", id),
+			zap.Error(err))
+		span.RecordError(err)
+		if !deadline.IsZero() && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			writeError(ctx, w, http.StatusGatewayTimeout, "request deadline exceeded")
+			return
+		}
+		writeDomainError(ctx, w, err, "db insert error")
+		return
+	}
+	if existingID != "" {
+		span.SetAttributes(attribute.String("job.existing_id", existingID))
+		if !ifAbsent {
+			writeError(ctx, w, http.StatusConflict, "a job with this external_ref already exists")
+			return
+		}
+		logger.Info("job already exists for external_ref",
+			zap.String("job_id", existingID),
+			zap.String("external_ref", externalRef))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"job_id": existingID, "existing": true})
+		return
+	}
+
+	if err := s.publishJob(ctx, id, tenant, jobType, deadline, nil); err != nil {
+		logger.Error("nats publish error",
+			zap.String("job_id", id),
+			zap.Error(err))
+		span.RecordError(err)
+		s.writeDispatchError(ctx, w, logger, id, err)
+		return
+	}
+
+	logger.Info("job created successfully",
+		zap.String("job_id", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"job_id": id})
+}
+
+// Job creation queries, also prepared during warmup.
+const (
+	insertJobSQL   = `INSERT INTO jobs (id, tenant, type, external_ref) VALUES ($1, $2, $3, nullif($4, '')) ON CONFLICT DO NOTHING`
+	existingJobSQL = `
+		SELECT id FROM jobs WHERE tenant = $1 AND external_ref = $2
+		UNION ALL
+		SELECT id FROM jobs_history WHERE tenant = $1 AND external_ref = $2
+		LIMIT 1`
+	archivedJobSQL = `SELECT id FROM jobs_history WHERE tenant = $1 AND external_ref = $2 LIMIT 1`
+)
+
+// insertJob stores a new job row. When externalRef is already taken for the
+// tenant, by a job in the hot table or in the archive, nothing is inserted
+// and the ID of the existing job is returned.
+func (s *Server) insertJob(ctx context.Context, id, tenant, jobType, externalRef string) (string, error) {
+	var existingID string
+	err := s.WithTx(ctx, func(tx pgx.Tx) error {
+		existingID = ""
+		qctx, cancel := storage.WithQuery(ctx, "insert_job")
+		defer cancel()
+		tag, err := tx.Exec(qctx, insertJobSQL, id, tenant, jobType, externalRef)
+		if err != nil || externalRef == "" {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return tx.QueryRow(qctx, existingJobSQL, tenant, externalRef).Scan(&existingID)
+		}
+		// The unique index only covers the hot table. Checking the archive
+		// after the insert also catches a job the janitor moved while the
+		// insert waited on its row.
+		err = tx.QueryRow(qctx, archivedJobSQL, tenant, externalRef).Scan(&existingID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(qctx, `DELETE FROM jobs WHERE id = $1`, id)
+		return err
+	})
+	return existingID, err
+}
+
+// publishJob hands a stored job and its payload, which may be nil, to the
+// workers in a contract.Job message, with the trace context on top.
+// Payloads of tenants with a data key are encrypted. The job is sent as a request and only counts as handed over
+// once a worker answers that it took it; see queue.DispatchAccepted.
+func (s *Server) publishJob(ctx context.Context, id, tenant, jobType string, deadline time.Time, payload []byte) error {
+	publishStart := time.Now()
+	msg, err := contract.Encode(contract.Job{
+		ID:          id,
+		Tenant:      tenant,
+		Type:        jobType,
+		Region:      s.region,
+		Deadline:    deadline,
+		PublishedAt: publishStart,
+		Payload:     payload,
+	}, s.payloadKeys)
+	if err != nil {
+		return err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, queue.HeaderCarrier(msg.Header))
+	label := queue.SubjectLabel(msg.Subject)
+
+	// A busy worker refuses the job, but the queue group may hand the
+	// next request to a replica with room.
+	for attempt := 1; ; attempt++ {
+		var reply *nats.Msg
+		rctx, cancel := context.WithTimeout(ctx, dispatchTimeout)
+		reply, err = s.nats.RequestMsgWithContext(rctx, msg)
+		cancel()
+		if err == nil && string(reply.Data) == queue.DispatchBusy {
+			err = errWorkersBusy
+		}
+		if !errors.Is(err, errWorkersBusy) || attempt == dispatchAttempts {
+			break
+		}
+	}
+	prom.NATSPublishDuration.WithLabelValues(s.serviceName, label).Observe(time.Since(publishStart).Seconds())
+	if err != nil {
+		prom.NATSPublishErrors.WithLabelValues(s.serviceName, label).Inc()
+		return err
+	}
+	prom.NATSMessagesPublished.WithLabelValues(s.serviceName, label).Inc()
+	return nil
+}
+
+// dispatchAttempts is how many workers publishJob asks before giving up on
+// a job they all refused.
+const dispatchAttempts = 3
+
+// dispatchTimeout bounds how long publishJob waits for a worker to answer
+// one dispatch request.
+var dispatchTimeout = config.Duration("JOB_DISPATCH_TIMEOUT", 2*time.Second)
+
+// errWorkersBusy is returned by publishJob when every worker asked refused
+// the job because its tenant's buffer was full.
+var errWorkersBusy = errors.New("workers are busy with this tenant's jobs")
+
+// dispatchRefused reports whether publishJob failed because no worker took
+// the job: all refused it, or none subscribes to its subject. The job was
+// then certainly not delivered and its row can be removed.
+func dispatchRefused(err error) bool {
+	return errors.Is(err, errWorkersBusy) || errors.Is(err, nats.ErrNoResponders)
+}
+
+// writeDispatchError answers a request whose job publishJob couldn't hand
+// to a worker. A job no worker took is deleted so it doesn't stay queued
+// forever, and the client is told to retry: with 429 when the tenant's
+// backlog is full, with 503 otherwise. After other failures, such as a
+// timeout, a worker may still have the job, so its row is kept.
+func (s *Server) writeDispatchError(ctx context.Context, w http.ResponseWriter, logger *zap.Logger, id string, err error) {
+	if dispatchRefused(err) {
+		if derr := s.discardJob(ctx, id); derr != nil {
+			logger.Error("failed to delete undelivered job",
+				zap.String("job_id", id),
+				zap.Error(derr))
+		}
+	}
+	if errors.Is(err, errWorkersBusy) {
+		writeRetryableError(ctx, w, http.StatusTooManyRequests, errorClassBacklog, dependencyRetryAfter, "too many queued jobs for this tenant")
+		return
+	}
+	writeDomainError(ctx, w, err, "nats publish error")
+}
+
+// discardJob deletes a job no worker took. It runs even if the request was
+// cancelled meanwhile, since the row would otherwise stay queued.
+func (s *Server) discardJob(ctx context.Context, id string) error {
+	ctx = context.WithoutCancel(ctx)
+	return s.WithTx(ctx, func(tx pgx.Tx) error {
+		qctx, cancel := storage.WithQuery(ctx, "discard_job")
+		defer cancel()
+		_, err := tx.Exec(qctx, `DELETE FROM jobs WHERE id = $1 AND status = 'queued'`, id)
+		return err
+	})
+}
+
+// probePath reports whether path is a probe or scrape endpoint, which
+// would otherwise dominate traces and per-client statistics.
+func probePath(path string) bool {
+	switch path {
+	case "/healthz", "/healthz/weight", "/readyz", "/status", "/metrics":
+		return true
+	}
+	return false
+}
+
+// routePattern is the pattern of the route r will be served by, e.g.
+// /v1/jobs/{id}, or "unmatched". Matching ahead of routing lets gauges
+// taken before the handler runs use it as a label, one series per route
+// rather than per path.
+func routePattern(routes chi.Routes, r *http.Request) string {
+	rctx := chi.NewRouteContext()
+	if !routes.Match(rctx, r.Method, r.URL.Path) || rctx.RoutePattern() == "" {
+		return "unmatched"
+	}
+	return rctx.RoutePattern()
+}
+
+func instrument(service string, logger *zap.Logger, admin adminKeys, dims *obs.Dimensions, clients *clientTracker, next chi.Router) http.Handler {
+	metered := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+
+		// Routes rather than paths, so /v1/jobs/{id} and friends are one
+		// series each
+		route := routePattern(next, r)
+		method := r.Method
+		traceID := span.SpanContext().TraceID().String()
+		if span.SpanContext().HasTraceID() {
+			w.Header().Set("X-Trace-Id", traceID)
+		}
+
+		if obs.DebugTraceEnabled(r.Context()) {
+			span.SetAttributes(attribute.Bool("debug.forced_sampling", true))
+		}
+
+		inFlight := prom.HTTPInFlight.WithLabelValues(service, route, method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		probe := probePath(r.URL.Path)
+		var client clientRequest
+		if !probe {
+			client = clients.start(clientOf(r))
+		}
+
+		start := time.Now()
+		rr := &respRecorder{ResponseWriter: w, code: 200}
+
+		next.ServeHTTP(rr, r)
+
+		if !probe {
+			clients.finish(client, route, method, rr.code)
+		}
+
+		duration := time.Since(start)
+		code := fmt.Sprintf("%d", rr.code)
+
+		// Update metrics
+		tenant, jobType := dims.Labels(r.Header.Get("X-Tenant-ID"), r.URL.Query().Get("type"))
+		prom.HTTPRequests.WithLabelValues(service, route, method, code, tenant, jobType).Inc()
+		prom.HTTPLatency.WithLabelValues(service, route, method, tenant).Observe(duration.Seconds())
+
+		// Name the span after the matched route so /v1/ingest/{source} and
+		// friends don't produce one span name per distinct path.
+		span.SetName(method + " " + route)
+		span.SetAttributes(attribute.String("http.route", route))
+		span.SetAttributes(attribute.Float64("http.duration_ms", float64(duration.Milliseconds())))
+
+		// Structured logging
+		logger.Info("http request",
+			zap.String("trace_id", traceID),
+			zap.String("method", method),
+			zap.String("route", route),
+			zap.String("path", r.URL.Path),
+			zap.Int("status_code", rr.code),
+			zap.Duration("duration", duration),
+		)
+	})
+
+	traced := otelhttp.NewHandler(metered, "http",
+		otelhttp.WithPropagators(obs.DebugGuardPropagator{TextMapPropagator: otel.GetTextMapPropagator()}),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+		// Probes and scrapes would otherwise dominate the trace volume
+		otelhttp.WithFilter(func(r *http.Request) bool { return !probePath(r.URL.Path) }),
+		// Webhook senders' trace context is linked rather than continued so
+		// third parties can't attach our spans to their traces.
+		otelhttp.WithPublicEndpointFn(func(r *http.Request) bool {
+			return strings.HasPrefix(r.URL.Path, "/v1/ingest/")
+		}),
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// X-Debug-Trace forces sampling of this request and the jobs it
+		// creates, but only for callers holding an admin key.
+		debugTrace := r.Header.Get("X-Debug-Trace") == "1" && admin.authorized(r)
+		traced.ServeHTTP(w, r.WithContext(obs.WithDebugTrace(r.Context(), debugTrace)))
+	})
+}
+
+func (s *Server) updateDBMetrics(serviceName string) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	pools := map[string]*pgxpool.Pool{"interactive": s.db, "background": s.bgdb}
+	emptyAcquires := make(map[string]int64)
+	for range ticker.C {
+		for name, db := range pools {
+			stats := db.Stat()
+			prom.DBConnections.WithLabelValues(serviceName, name).Set(float64(stats.AcquiredConns()))
+			prom.DBMaxConnections.WithLabelValues(serviceName, name).Set(float64(stats.MaxConns()))
+			prom.DBEmptyAcquires.WithLabelValues(serviceName, name).Add(float64(stats.EmptyAcquireCount() - emptyAcquires[name]))
+			emptyAcquires[name] = stats.EmptyAcquireCount()
+		}
+	}
+}
+
+type respRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *respRecorder) WriteHeader(statusCode int) {
+	r.code = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/fakes"
+	"codigo/internal/metrics"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+// benchServer returns a server on an in-memory Postgres and NATS, with a
+// worker that accepts every job.
+func benchServer(b *testing.B) *Server {
+	b.Helper()
+	if prom == nil {
+		prom = metrics.NewAPI(prometheus.NewRegistry())
+	}
+	logger := zap.NewNop()
+	db, err := fakes.PostgresPool(context.Background(), func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.Tracer = &storage.SlowQueryTracer{Logger: logger, Threshold: storage.QueryTimeout}
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(db.Close)
+
+	broker := fakes.NewNATS()
+	worker, err := broker.Connect()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(worker.Close)
+	if _, err := worker.QueueSubscribe("jobs.>", "workers", func(m *nats.Msg) {
+		m.Respond([]byte(queue.DispatchAccepted))
+	}); err != nil {
+		b.Fatal(err)
+	}
+	if err := worker.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	nc, err := broker.Connect()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(nc.Close)
+
+	keys, err := queue.LoadKeyring()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &Server{
+		serviceName: "codigo-api",
+		db:          db,
+		bgdb:        db,
+		nats:        nc,
+		logger:      logger,
+		maintenance: newMaintenanceMode("codigo-api", logger),
+		clients:     newClientTracker("codigo-api"),
+		payloadKeys: keys,
+	}
+}
+
+// BenchmarkCreateJob measures POST /v1/jobs through the middleware stack
+// the API serves it with, down to the insert and the dispatch request.
+func BenchmarkCreateJob(b *testing.B) {
+	s := benchServer(b)
+	cfg := config.App{ServiceName: s.serviceName}
+	h := instrument(s.serviceName, s.logger, s.admin, nil, s.clients, newRouter(cfg, metrics.Config{}, s, s.logger))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		r := httptest.NewRequest(http.MethodPost, "/v1/jobs?type=report", nil)
+		r.Header.Set("X-Tenant-ID", "acme")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatalf("POST /v1/jobs = %d %s", w.Code, w.Body)
+		}
+	}
+}
+
+// BenchmarkPublishJob measures handing a stored job to a worker: encoding,
+// the dispatch request and its reply.
+func BenchmarkPublishJob(b *testing.B) {
+	s := benchServer(b)
+	ctx := context.Background()
+	payload := []byte(`{"ref":"refs/heads/main","size":3}`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := s.publishJob(ctx, "job_1", "acme", "report", time.Time{}, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"codigo/internal/jobs"
+)
+
+// errorClassMaintenance marks requests refused during a maintenance window;
+// clients should retry after Retry-After.
+const errorClassMaintenance = "maintenance"
+
+// defaultMaintenanceRetryAfter is suggested when the window has no end.
+const defaultMaintenanceRetryAfter = time.Minute
+
+// maintenanceMode is this replica's view of the maintenance state. It starts
+// from MAINTENANCE_MODE and follows MaintenanceSubject, so a switch made on
+// one replica reaches all of them and the workers.
+type maintenanceMode struct {
+	serviceName string
+	logger      *zap.Logger
+
+	mu    sync.Mutex
+	state jobs.Maintenance
+}
+
+func newMaintenanceMode(serviceName string, logger *zap.Logger) *maintenanceMode {
+	m := &maintenanceMode{serviceName: serviceName, logger: logger}
+	if os.Getenv("MAINTENANCE_MODE") == "true" {
+		m.state = jobs.Maintenance{Enabled: true, Reason: os.Getenv("MAINTENANCE_REASON"), SetAt: time.Now()}
+		prom.MaintenanceMode.WithLabelValues(serviceName).Set(1)
+	} else {
+		prom.MaintenanceMode.WithLabelValues(serviceName).Set(0)
+	}
+	return m
+}
+
+func (m *maintenanceMode) current() jobs.Maintenance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// apply adopts state unless a newer one is already in place.
+func (m *maintenanceMode) apply(state jobs.Maintenance) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if state.SetAt.Before(m.state.SetAt) {
+		return
+	}
+	changed := state.Enabled != m.state.Enabled
+	m.state = state
+	if changed {
+		m.logger.Warn("maintenance mode changed",
+			zap.Bool("enabled", state.Enabled),
+			zap.String("reason", state.Reason))
+		enabled := 0.0
+		if state.Enabled {
+			enabled = 1
+		}
+		prom.MaintenanceMode.WithLabelValues(m.serviceName).Set(enabled)
+	}
+}
+
+// observe applies an announcement from any replica, this one included.
+func (m *maintenanceMode) observe(msg *nats.Msg) {
+	var state jobs.Maintenance
+	if err := json.Unmarshal(msg.Data, &state); err != nil {
+		m.logger.Warn("invalid maintenance announcement", zap.Error(err))
+		return
+	}
+	m.apply(state)
+}
+
+// announce publishes the current state every interval while maintenance is
+// on, so workers and replicas that start mid-window pick it up.
+func (m *maintenanceMode) announce(nc *nats.Conn, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if state := m.current(); state.Enabled {
+			if err := publishMaintenance(nc, state); err != nil {
+				m.logger.Warn("failed to announce maintenance mode", zap.Error(err))
+			}
+		}
+	}
+}
+
+func publishMaintenance(nc *nats.Conn, state jobs.Maintenance) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return nc.Publish(jobs.MaintenanceSubject, data)
+}
+
+// refuseDuringMaintenance answers 503 with the maintenance details and
+// reports true while maintenance is on. Endpoints that create jobs call it
+// first; reads and probes don't.
+func (s *Server) refuseDuringMaintenance(w http.ResponseWriter, r *http.Request) bool {
+	state := s.maintenance.current()
+	if !state.Enabled {
+		return false
+	}
+	retryAfter := defaultMaintenanceRetryAfter
+	if state.Until != nil {
+		retryAfter = max(time.Until(*state.Until), time.Second)
+	}
+	msg := "job creation is paused for maintenance"
+	if state.Reason != "" {
+		msg += ": " + state.Reason
+	}
+	writeRetryableError(r.Context(), w, http.StatusServiceUnavailable, errorClassMaintenance, retryAfter, msg)
+	return true
+}
+
+type maintenanceRequest struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason"`
+	Until   *time.Time `json:"until"`
+}
+
+// getMaintenance returns the maintenance state.
+func (s *Server) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.maintenance.current())
+}
+
+// setMaintenance switches maintenance mode on or off for every API replica
+// and worker and returns the new state.
+func (s *Server) setMaintenance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req maintenanceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(ctx, w, http.StatusBadRequest, "body must be JSON like {\"enabled\": true, \"reason\": \"...\", \"until\": \"2026-01-02T03:00:00Z\"}")
+		return
+	}
+	state := jobs.Maintenance{Enabled: req.Enabled, SetAt: time.Now()}
+	if req.Enabled {
+		state.Reason = req.Reason
+		state.Until = req.Until
+	}
+	s.maintenance.apply(state)
+	if err := publishMaintenance(s.nats, state); err != nil {
+		s.logger.Error("failed to announce maintenance mode", zap.Error(err))
+		writeDomainError(ctx, w, err, "maintenance mode changed on this replica only; nats publish failed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/jobs"
+	"codigo/internal/metrics"
+	"codigo/internal/obs"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+// The API is assembled from these fx modules. A new component gets its own
+// module, or a constructor in the module whose dependencies it shares, and
+// is listed in main; nothing else needs to know about it.
+var (
+	configModule = fx.Module("config",
+		fx.Provide(loadAppConfig),
+	)
+	loggingModule = fx.Module("logging",
+		fx.Provide(obs.NewLogger),
+	)
+	telemetryModule = fx.Module("telemetry",
+		fx.Provide(newMetricsRegistry, newTracing),
+	)
+	storageModule = fx.Module("storage",
+		fx.Provide(newVault, newDBPools),
+	)
+	queueModule = fx.Module("queue",
+		fx.Provide(newNATSConn, newCompletionStream),
+	)
+	serverModule = fx.Module("server",
+		fx.Provide(newServer),
+		fx.Invoke(startBackgroundWork),
+	)
+	httpModule = fx.Module("http",
+		fx.Provide(newRouter),
+		fx.Invoke(serveMetrics, serveAPI),
+	)
+)
+
+// loadAppConfig is the configuration every module shares.
+func loadAppConfig() config.App {
+	return config.LoadApp("codigo-api")
+}
+
+// newMetricsRegistry creates the API metrics on the registry /metrics
+// serves.
+func newMetricsRegistry() (metrics.Config, *prometheus.Registry, error) {
+	cfg, err := metrics.ParseConfig(config.String("METRICS_PREFIX", ""), config.String("METRICS_CONST_LABELS", ""))
+	if err != nil {
+		return metrics.Config{}, nil, fmt.Errorf("invalid metrics configuration: %w", err)
+	}
+	registry, registerer := metrics.NewRegistry(cfg)
+	prom = metrics.NewAPI(registerer)
+	return cfg, registry, nil
+}
+
+// tracing is provided once the global OpenTelemetry tracer provider and
+// propagator are set. Components that capture them when built, like the
+// otelhttp handler, depend on it.
+type tracing struct{}
+
+func newTracing(lc fx.Lifecycle, cfg config.App) tracing {
+	shutdown := obs.InitTracing(context.Background(), cfg.ServiceName, cfg.Region, &prom.Common)
+	lc.Append(fx.StopHook(shutdown))
+	return tracing{}
+}
+
+// newVault is the optional Vault client for Postgres and NATS credentials;
+// nil without VAULT_ADDR.
+func newVault() (*config.Vault, error) {
+	vault, err := config.NewVault(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize vault client: %w", err)
+	}
+	return vault, nil
+}
+
+// dbPools are the interactive and background Postgres pools.
+type dbPools struct {
+	interactive *pgxpool.Pool
+	background  *pgxpool.Pool
+}
+
+// newDBPools opens both pools with shared credentials and settings;
+// DB_INTERACTIVE_MAX_CONNS and DB_BACKGROUND_MAX_CONNS size them
+// independently.
+func newDBPools(lc fx.Lifecycle, logger *zap.Logger, vault *config.Vault) (dbPools, error) {
+	pools, err := storage.Open(context.Background(), logger, vault,
+		func(cfg *pgxpool.Config) {
+			cfg.MaxConns = int32(config.Int("DB_INTERACTIVE_MAX_CONNS", int(cfg.MaxConns)))
+			cfg.MinConns = min(int32(config.Int("DB_INTERACTIVE_MIN_CONNS", 2)), cfg.MaxConns)
+		},
+		func(cfg *pgxpool.Config) {
+			cfg.MaxConns = int32(config.Int("DB_BACKGROUND_MAX_CONNS", 2))
+			cfg.MinConns = 0
+		},
+	)
+	if err != nil {
+		return dbPools{}, fmt.Errorf("failed to open database pools: %w", err)
+	}
+	db, bgdb := pools[0], pools[1]
+	lc.Append(fx.StopHook(func() {
+		bgdb.Close()
+		db.Close()
+	}))
+	return dbPools{interactive: db, background: bgdb}, nil
+}
+
+func newNATSConn(lc fx.Lifecycle, logger *zap.Logger, vault *config.Vault) (*nats.Conn, error) {
+	nc, err := queue.Connect(logger, vault)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	lc.Append(fx.StopHook(nc.Close))
+	return nc, nil
+}
+
+// newCompletionStream is the jobs.completed publisher for results this
+// binary records; nil without JOBS_COMPLETED_STREAM.
+func newCompletionStream(nc *nats.Conn) (*jobs.CompletionStream, error) {
+	stream := config.String("JOBS_COMPLETED_STREAM", "")
+	if stream == "" {
+		return nil, nil
+	}
+	cs, err := jobs.NewCompletionStream(nc, stream, config.Duration("JOBS_COMPLETED_MAX_AGE", 72*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the job completion stream: %w", err)
+	}
+	return cs, nil
+}
+
+func newServer(cfg config.App, pools dbPools, nc *nats.Conn, completed *jobs.CompletionStream, logger *zap.Logger) (*Server, error) {
+	payloadKeys, err := queue.LoadKeyring()
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload encryption keys: %w", err)
+	}
+	s := &Server{
+		serviceName: cfg.ServiceName,
+
+		db:      pools.interactive,
+		bgdb:    pools.background,
+		nats:    nc,
+		logger:  logger,
+		region:  cfg.Region,
+		admin:   loadAdminKeys(),
+		workers: newWorkerRegistry(config.Duration("WORKER_STALE_AFTER", 30*time.Second), logger),
+		ingest:  loadIngestSources(logger),
+
+		maintenance: newMaintenanceMode(cfg.ServiceName, logger),
+		clients:     newClientTracker(cfg.ServiceName),
+
+		payloadKeys: payloadKeys,
+		completed:   completed,
+	}
+	if err := s.ensureSchema(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to ensure database schema: %w", err)
+	}
+	return s, nil
+}
+
+// startBackgroundWork starts what the API does besides serving requests:
+// pool metrics, recording worker results and heartbeats, maintenance
+// announcements, the janitor, the job cost rollup and the job cohort
+// exporter.
+func startBackgroundWork(lc fx.Lifecycle, cfg config.App, s *Server) {
+	lc.Append(fx.StartHook(func() error {
+		go s.updateDBMetrics(cfg.ServiceName)
+
+		// Record results published by workers running in
+		// WORKER_RESULT_MODE=nats. The queue group makes each event land on
+		// exactly one API replica.
+		resultsSubject := config.String("RESULTS_SUBJECT", "jobs.results")
+		var err error
+		s.results, err = s.nats.QueueSubscribe(resultsSubject, "codigo-api-results", func(m *nats.Msg) {
+			s.recordResult(cfg.ServiceName, m)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to job results: %w", err)
+		}
+
+		// Track the worker fleet from heartbeats; no queue group, every
+		// replica needs the full picture.
+		if _, err := s.nats.Subscribe(jobs.HeartbeatSubject, s.workers.observe); err != nil {
+			return fmt.Errorf("failed to subscribe to worker heartbeats: %w", err)
+		}
+
+		// Follow maintenance switches made on any replica and keep
+		// announcing an active window for workers that start during it
+		if _, err := s.nats.Subscribe(jobs.MaintenanceSubject, s.maintenance.observe); err != nil {
+			return fmt.Errorf("failed to subscribe to maintenance announcements: %w", err)
+		}
+		go s.maintenance.announce(s.nats, config.Duration("MAINTENANCE_ANNOUNCE_INTERVAL", 10*time.Second))
+
+		// Move old terminal jobs out of the hot table; JOB_ARCHIVE_AFTER=0
+		// disables it
+		if archiveAfter := config.Duration("JOB_ARCHIVE_AFTER", 7*24*time.Hour); archiveAfter > 0 {
+			go s.runJanitor(cfg.ServiceName, archiveAfter, config.Duration("JOB_ARCHIVE_INTERVAL", time.Hour))
+		}
+
+		// Daily cost totals for /v1/stats/costs; JOB_COST_ROLLUP_INTERVAL=0
+		// disables it
+		if interval := config.Duration("JOB_COST_ROLLUP_INTERVAL", time.Minute); interval > 0 {
+			go s.runCostRollup(config.Duration("JOB_COST_ROLLUP_LAG", time.Minute), interval)
+		}
+
+		// Success rate and latency per age cohort, computed from the jobs
+		// table; JOB_COHORT_INTERVAL=0 disables it
+		if interval := config.Duration("JOB_COHORT_INTERVAL", time.Minute); interval > 0 {
+			go s.runCohortExporter(cfg.ServiceName, interval)
+		}
+		return nil
+	}))
+}
+
+func newRouter(cfg config.App, metricsConfig metrics.Config, s *Server, logger *zap.Logger) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(s.scoped)
+
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte("ok"))
+	})
+
+	r.Get("/readyz", s.readyz)
+	r.Get("/healthz/weight", s.loadWeight)
+
+	slos := &sloManifest{Service: cfg.ServiceName}
+	slos.route(r, http.MethodPost, "/v1/jobs", routeSLO{
+		LatencyTarget:     500 * time.Millisecond,
+		LatencyPercentile: 0.95,
+		Availability:      availabilityCritical,
+	}, s.createJob)
+	slos.route(r, http.MethodGet, "/v1/jobs", routeSLO{
+		LatencyTarget:     2 * time.Second,
+		LatencyPercentile: 0.95,
+		Availability:      availabilityStandard,
+	}, s.listJobs)
+	slos.route(r, http.MethodGet, "/v1/stats/costs", routeSLO{
+		LatencyTarget:     2 * time.Second,
+		LatencyPercentile: 0.95,
+		Availability:      availabilityStandard,
+	}, s.jobCosts)
+	r.Get("/v1/jobs/{id}", s.getJob)
+	r.Get("/v1/jobs/{id}/wait", s.waitJob)
+	r.Get("/v1/jobs/{id}/logs", s.jobLogs)
+	r.Method(http.MethodGet, "/slo-manifest.json", slos)
+	// Embedded SLO evaluation for deployments without the reporter cron job
+	if promURL := os.Getenv("SLO_PROMETHEUS_URL"); promURL != "" {
+		s.slo = newSLOEvaluator(strings.TrimSuffix(promURL, "/"), cfg.ServiceName, metricsConfig, slos, logger)
+		go s.slo.run(config.Duration("SLO_EVAL_INTERVAL", 5*time.Minute))
+		r.Method(http.MethodGet, "/v1/slo", s.slo)
+	}
+	// Public overall status for external status pages
+	r.Method(http.MethodGet, "/status", &statusPage{s: s})
+
+	// Third-party webhooks, authenticated by per-source HMAC signatures
+	r.Post("/v1/ingest/{source}", s.ingestWebhook)
+
+	// Admin endpoints require a key from ADMIN_API_KEYS
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(s.admin.require)
+		r.Get("/workers", s.listWorkers)
+		r.Get("/capacity", s.restartCapacity)
+		r.Get("/topology", s.topology)
+		r.Post("/reconnect/{dependency}", s.reconnect)
+		r.Get("/top-clients", s.topClients)
+		r.Get("/maintenance", s.getMaintenance)
+		r.Put("/maintenance", s.setMaintenance)
+	})
+	return r
+}
+
+// serveMetrics exposes the registry on the API router unless METRICS_ADDR
+// or metrics mTLS give it a listener of its own.
+func serveMetrics(lc fx.Lifecycle, logger *zap.Logger, registry *prometheus.Registry, r *chi.Mux) error {
+	return obs.ServeMetrics(lc, logger, registry, r.Handle)
+}
+
+// serveAPI serves the router on HTTP_ADDR until the app stops, then shuts
+// down cleanly so in-flight requests finish and a Unix socket file is
+// removed before the pod goes away. fx stops the app on SIGTERM and SIGINT
+// and bounds the shutdown by SHUTDOWN_TIMEOUT.
+func serveAPI(lc fx.Lifecycle, _ tracing, cfg config.App, logger *zap.Logger, s *Server, r *chi.Mux) error {
+	l, err := config.Listen("http", config.String("HTTP_ADDR", ":8080"))
+	if err != nil {
+		return fmt.Errorf("api listener failed: %w", err)
+	}
+	dims := obs.LoadDimensions()
+	dims.DeleteEvicted(prom.HTTPRequests.MetricVec, prom.HTTPLatency.MetricVec)
+	srv := newHTTPServer(cfg.ServiceName, instrument(cfg.ServiceName, logger, s.admin, dims, s.clients, r))
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			// Readiness stays false until connections and statements are
+			// warm, so the first requests after a deploy don't pay for them.
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), config.Duration("WARMUP_TIMEOUT", 30*time.Second))
+				defer cancel()
+				s.warmup(ctx)
+			}()
+
+			go func() {
+				logger.Info("api server starting", zap.String("address", l.Addr().String()))
+				if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+					logger.Fatal("api server failed", zap.Error(err))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			logger.Info("api server shutting down")
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Warn("api server shutdown incomplete", zap.Error(err))
+			}
+			return nil
+		},
+	})
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// reconnectTimeout bounds how long a reconnect waits for the dependency to
+// come back before reporting its status.
+const reconnectTimeout = 5 * time.Second
+
+// reconnect re-establishes the connections to {dependency} without a pod
+// restart, for recovering from wedged connections. For postgres both pools
+// close their idle connections at once and the ones in use as they are
+// released, so running queries finish. For nats the client drops its
+// connection and reconnects, keeping its subscriptions. The response is the
+// dependency's status afterwards, as in /admin/topology.
+func (s *Server) reconnect(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dependency := chi.URLParam(r, "dependency")
+
+	var status func(context.Context) dependencyStatus
+	switch dependency {
+	case "postgres":
+		s.db.Reset()
+		s.bgdb.Reset()
+		status = s.postgresStatus
+	case "nats":
+		if err := s.nats.ForceReconnect(); err != nil {
+			writeError(ctx, w, http.StatusConflict, "nats connection is closed: "+err.Error())
+			return
+		}
+		status = func(context.Context) dependencyStatus { return s.natsStatus() }
+	default:
+		writeError(ctx, w, http.StatusNotFound, "dependency must be postgres or nats")
+		return
+	}
+	s.logger.Warn("dependency reconnect requested", zap.String("dependency", dependency))
+
+	deadline := time.Now().Add(reconnectTimeout)
+	d := status(ctx)
+	for !d.Healthy && time.Now().Before(deadline) && ctx.Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+		d = status(ctx)
+	}
+	if !d.Healthy {
+		s.logger.Warn("dependency unhealthy after reconnect",
+			zap.String("dependency", dependency),
+			zap.String("error", d.Error))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"codigo/internal/obs"
+	"codigo/internal/queue"
+)
+
+// Principals a request can act as.
+const (
+	principalAnonymous = "anonymous"
+	principalAdmin     = "admin"
+)
+
+// requestFlags are per-request feature switches.
+type requestFlags struct {
+	// DebugTrace forces sampling of the request and the jobs it creates.
+	DebugTrace bool
+}
+
+// requestScope holds what the middleware chain learned about a request, so
+// handlers read it from one place instead of re-parsing headers.
+type requestScope struct {
+	Principal string
+	Tenant    string
+	// Logger carries the request's trace ID and tenant.
+	Logger *zap.Logger
+	// Deadline is the client's deadline, or zero when it set none.
+	Deadline time.Time
+	Flags    requestFlags
+}
+
+type requestScopeKey struct{}
+
+func withRequestScope(ctx context.Context, scope *requestScope) context.Context {
+	return context.WithValue(ctx, requestScopeKey{}, scope)
+}
+
+// scopeFrom returns the request's scope. Outside an HTTP request, e.g. for
+// results received from NATS, it returns an anonymous default-tenant scope
+// logging with the server's logger.
+func (s *Server) scopeFrom(ctx context.Context) *requestScope {
+	if scope, ok := ctx.Value(requestScopeKey{}).(*requestScope); ok {
+		return scope
+	}
+	return &requestScope{Principal: principalAnonymous, Tenant: queue.DefaultToken, Logger: s.logger}
+}
+
+// scoped builds the request scope and rejects requests whose deadline
+// header is malformed or already passed.
+func (s *Server) scoped(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		scope := &requestScope{
+			Principal: principalAnonymous,
+			Tenant:    r.Header.Get("X-Tenant-ID"),
+			Flags:     requestFlags{DebugTrace: obs.DebugTraceEnabled(ctx)},
+		}
+		if s.admin.authorized(r) {
+			scope.Principal = principalAdmin
+		}
+		if scope.Tenant == "" {
+			scope.Tenant = queue.DefaultToken
+		}
+
+		deadline, err := requestDeadline(r, time.Now())
+		if errors.Is(err, errDeadlinePassed) {
+			writeError(ctx, w, http.StatusGatewayTimeout, err.Error())
+			return
+		} else if err != nil {
+			writeError(ctx, w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scope.Deadline = deadline
+
+		fields := []zap.Field{zap.String("tenant", scope.Tenant)}
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+		}
+		scope.Logger = s.logger.With(fields...)
+
+		next.ServeHTTP(w, r.WithContext(withRequestScope(ctx, scope)))
+	})
+}
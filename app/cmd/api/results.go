@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"codigo/internal/jobs"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+// recordResult applies a worker completion event to the jobs table. The API
+// owns these writes so workers can run without database access.
+func (s *Server) recordResult(serviceName string, m *nats.Msg) {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), queue.HeaderCarrier(m.Header))
+	ctx, span := otel.Tracer("codigo-api").Start(ctx, "recordResult")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+
+	var res jobs.Result
+	if err := json.Unmarshal(m.Data, &res); err != nil || res.JobID == "" || res.Status == "" {
+		s.logger.Error("invalid job result event",
+			zap.String("trace_id", traceID),
+			zap.String("subject", m.Subject),
+			zap.Error(err))
+		prom.JobResultsRecorded.WithLabelValues(serviceName, "invalid").Inc()
+		return
+	}
+	span.SetAttributes(
+		attribute.String("job.id", res.JobID),
+		attribute.String("job.status", res.Status),
+	)
+	if origin := m.Header.Get(queue.RegionHeader); origin != "" {
+		span.SetAttributes(attribute.String("job.worker_region", origin))
+	}
+
+	var attempt int
+	err := s.WithTx(ctx, func(tx pgx.Tx) error {
+		qctx, cancel := storage.WithQuery(ctx, "record_job_result")
+		defer cancel()
+		var err error
+		attempt, err = jobs.Record(qctx, tx, res)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("database error - record job result",
+			zap.String("trace_id", traceID),
+			zap.String("job_id", res.JobID),
+			zap.Error(err))
+		span.RecordError(err)
+		prom.JobResultsRecorded.WithLabelValues(serviceName, "error").Inc()
+		return
+	}
+	prom.JobResultsRecorded.WithLabelValues(serviceName, "ok").Inc()
+
+	// The result is stored either way; a lost event is logged and counted
+	// rather than failing the recording.
+	if s.completed == nil {
+		return
+	}
+	if err := s.completed.Publish(ctx, res, attempt); err != nil {
+		s.logger.Error("failed to publish job completion",
+			zap.String("trace_id", traceID),
+			zap.String("job_id", res.JobID),
+			zap.Error(err))
+		prom.JobCompletedEvents.WithLabelValues(serviceName, "error").Inc()
+		return
+	}
+	prom.JobCompletedEvents.WithLabelValues(serviceName, "ok").Inc()
+}
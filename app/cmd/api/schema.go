@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+
+	"codigo/internal/storage"
+)
+
+// schemaDDL creates the tables the API and worker rely on. It is idempotent
+// and runs once at startup rather than on the request path, because ALTER
+// TABLE takes an exclusive lock even when the column already exists.
+const schemaDDL = `
+CREATE TABLE IF NOT EXISTS jobs (id text primary key, created_at timestamptz default now(), status text default 'queued');
+ALTER TABLE jobs
+	ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default',
+	ADD COLUMN IF NOT EXISTS type text NOT NULL DEFAULT 'default',
+	ADD COLUMN IF NOT EXISTS external_ref text;
+CREATE INDEX IF NOT EXISTS jobs_created_at ON jobs (created_at);
+CREATE INDEX IF NOT EXISTS jobs_tenant_created_at ON jobs (tenant, created_at, id);
+CREATE INDEX IF NOT EXISTS jobs_tenant_status_created_at ON jobs (tenant, status, created_at, id);
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_tenant_external_ref ON jobs (tenant, external_ref) WHERE external_ref IS NOT NULL;
+CREATE TABLE IF NOT EXISTS jobs_history (id text primary key, created_at timestamptz, status text, archived_at timestamptz default now());
+ALTER TABLE jobs_history
+	ADD COLUMN IF NOT EXISTS tenant text,
+	ADD COLUMN IF NOT EXISTS type text,
+	ADD COLUMN IF NOT EXISTS external_ref text;
+CREATE INDEX IF NOT EXISTS jobs_history_tenant_created_at ON jobs_history (tenant, created_at, id);
+CREATE INDEX IF NOT EXISTS jobs_history_tenant_external_ref ON jobs_history (tenant, external_ref) WHERE external_ref IS NOT NULL;
+CREATE TABLE IF NOT EXISTS job_attempts (
+	id bigserial PRIMARY KEY,
+	job_id text NOT NULL,
+	attempt int NOT NULL,
+	worker text NOT NULL,
+	trace_id text,
+	started_at timestamptz NOT NULL,
+	finished_at timestamptz NOT NULL,
+	status text NOT NULL,
+	error text,
+	UNIQUE (job_id, attempt)
+);
+ALTER TABLE job_attempts ADD COLUMN IF NOT EXISTS logs text;
+ALTER TABLE job_attempts
+	ADD COLUMN IF NOT EXISTS tenant text,
+	ADD COLUMN IF NOT EXISTS type text,
+	ADD COLUMN IF NOT EXISTS wall_seconds double precision,
+	ADD COLUMN IF NOT EXISTS cpu_seconds double precision;
+CREATE INDEX IF NOT EXISTS job_attempts_tenant_finished_at ON job_attempts (tenant, finished_at);
+ALTER TABLE job_attempts ADD COLUMN IF NOT EXISTS recorded_at timestamptz NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS job_attempts_recorded_at ON job_attempts (recorded_at);
+CREATE TABLE IF NOT EXISTS job_costs (
+	tenant text NOT NULL,
+	type text NOT NULL,
+	day date NOT NULL,
+	jobs bigint NOT NULL,
+	wall_seconds double precision NOT NULL,
+	cpu_seconds double precision NOT NULL,
+	PRIMARY KEY (tenant, day, type)
+);
+CREATE TABLE IF NOT EXISTS job_costs_rollup (
+	id boolean PRIMARY KEY DEFAULT true CHECK (id),
+	rolled_up_to bigint NOT NULL DEFAULT 0,
+	rolled_up_at timestamptz
+);
+INSERT INTO job_costs_rollup DEFAULT VALUES ON CONFLICT DO NOTHING;
+`
+
+func (s *Server) ensureSchema(ctx context.Context) error {
+	qctx, cancel := storage.WithQuery(ctx, "ensure_schema")
+	defer cancel()
+	_, err := s.bgdb.Exec(qctx, schemaDDL)
+	return err
+}
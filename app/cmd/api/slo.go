@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// availabilityClass groups routes by how much unavailability they tolerate.
+type availabilityClass string
+
+const (
+	availabilityCritical   availabilityClass = "critical"
+	availabilityStandard   availabilityClass = "standard"
+	availabilityBestEffort availabilityClass = "best-effort"
+)
+
+var availabilityTargets = map[availabilityClass]float64{
+	availabilityCritical:   0.999,
+	availabilityStandard:   0.99,
+	availabilityBestEffort: 0.95,
+}
+
+// routeSLO is the SLO a route declares next to its handler.
+type routeSLO struct {
+	LatencyTarget     time.Duration
+	LatencyPercentile float64
+	Availability      availabilityClass
+}
+
+type sloManifestRoute struct {
+	Method               string            `json:"method"`
+	Route                string            `json:"route"`
+	LatencyTargetSeconds float64           `json:"latency_target_seconds"`
+	LatencyPercentile    float64           `json:"latency_percentile"`
+	AvailabilityClass    availabilityClass `json:"availability_class"`
+	AvailabilityTarget   float64           `json:"availability_target"`
+}
+
+// sloManifest collects the SLOs declared by routes and serves them as JSON
+// so the SLO reporter can evaluate what the code actually promises.
+type sloManifest struct {
+	Service string             `json:"service"`
+	Routes  []sloManifestRoute `json:"routes"`
+}
+
+// route registers h on r and records its SLO in the manifest.
+func (m *sloManifest) route(r chi.Router, method, pattern string, slo routeSLO, h http.HandlerFunc) {
+	r.Method(method, pattern, h)
+	m.Routes = append(m.Routes, sloManifestRoute{
+		Method:               method,
+		Route:                pattern,
+		LatencyTargetSeconds: slo.LatencyTarget.Seconds(),
+		LatencyPercentile:    slo.LatencyPercentile,
+		AvailabilityClass:    slo.Availability,
+		AvailabilityTarget:   availabilityTargets[slo.Availability],
+	})
+}
+
+func (m *sloManifest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"codigo/internal/metrics"
+)
+
+// sloWindowDays is the evaluation window, the same as the SLO reporter's.
+const sloWindowDays = 30
+
+// sloResult is one evaluated SLO. The JSON field names match the SLO
+// reporter's report so tooling can read either.
+type sloResult struct {
+	SLI              string  `json:"sli"`
+	Kind             string  `json:"kind"` // availability or latency
+	Method           string  `json:"method"`
+	Route            string  `json:"route"`
+	CurrentValue     float64 `json:"current_value"`
+	Target           float64 `json:"target"`
+	ErrorBudget      float64 `json:"error_budget"`
+	ErrorBudgetSpent float64 `json:"error_budget_spent"`
+	ErrorBudgetLeft  float64 `json:"error_budget_left"`
+	BurnRate         float64 `json:"burn_rate"`
+	Status           string  `json:"status"` // healthy, warning or breached
+}
+
+type sloEvaluation struct {
+	EvaluatedAt time.Time   `json:"evaluated_at"`
+	WindowDays  int         `json:"window_days"`
+	SLOs        []sloResult `json:"slos"`
+}
+
+// sloEvaluator periodically evaluates the SLOs the routes declare against
+// Prometheus, so small deployments get SLO status without running the
+// reporter. The math follows tools/slo-reporter.
+type sloEvaluator struct {
+	prometheusURL string
+	client        *http.Client
+	service       string
+	metrics       metrics.Config // names and install labels of the queried series
+	manifest      *sloManifest
+	logger        *zap.Logger
+
+	mu   sync.RWMutex
+	last *sloEvaluation
+}
+
+func newSLOEvaluator(prometheusURL, service string, metricsConfig metrics.Config, manifest *sloManifest, logger *zap.Logger) *sloEvaluator {
+	return &sloEvaluator{
+		prometheusURL: prometheusURL,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		service:       service,
+		metrics:       metricsConfig,
+		manifest:      manifest,
+		logger:        logger,
+	}
+}
+
+// run evaluates every interval until the process exits.
+func (e *sloEvaluator) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		eval, err := e.evaluate(ctx)
+		cancel()
+		if err != nil {
+			prom.SLOEvaluations.WithLabelValues(e.service, "error").Inc()
+			e.logger.Warn("slo evaluation failed", zap.Error(err))
+		} else {
+			prom.SLOEvaluations.WithLabelValues(e.service, "ok").Inc()
+			for _, r := range eval.SLOs {
+				prom.SLOBudgetLeft.WithLabelValues(e.service, r.Method, r.Route, r.Kind).Set(r.ErrorBudgetLeft)
+				prom.SLOBurnRate.WithLabelValues(e.service, r.Method, r.Route, r.Kind).Set(r.BurnRate)
+			}
+			e.mu.Lock()
+			e.last = eval
+			e.mu.Unlock()
+		}
+		<-ticker.C
+	}
+}
+
+// evaluate computes an availability and a latency SLO per manifest route.
+func (e *sloEvaluator) evaluate(ctx context.Context) (*sloEvaluation, error) {
+	rng := fmt.Sprintf("%dd", sloWindowDays)
+	eval := &sloEvaluation{EvaluatedAt: time.Now().UTC(), WindowDays: sloWindowDays}
+	requests := e.metrics.Name("http_requests_total")
+	buckets := e.metrics.Name("http_request_duration_seconds_bucket")
+	for _, rt := range e.manifest.Routes {
+		selector := fmt.Sprintf(`service=%q, route=%q, method=%q`, e.manifest.Service, rt.Route, rt.Method)
+		// Only this install's series when several share the Prometheus
+		if install := e.metrics.Selector(); install != "" {
+			selector += ", " + install
+		}
+		if rt.AvailabilityTarget > 0 {
+			good, err := e.query(ctx, fmt.Sprintf(
+				`sum(rate(%s{%s, code!~"5.."}[%s])) / sum(rate(%s{%s}[%s]))`,
+				requests, selector, rng, requests, selector, rng))
+			if err != nil {
+				return nil, fmt.Errorf("availability %s %s: %w", rt.Method, rt.Route, err)
+			}
+			if math.IsNaN(good) {
+				// No traffic in the window, so no latency either
+				continue
+			}
+			budget := 1 - rt.AvailabilityTarget
+			eval.SLOs = append(eval.SLOs, newSLOResult(
+				fmt.Sprintf("Availability %s %s (%s)", rt.Method, rt.Route, rt.AvailabilityClass),
+				"availability", rt, good, rt.AvailabilityTarget, budget, (1-good)/budget))
+		}
+		if rt.LatencyTargetSeconds > 0 && rt.LatencyPercentile > 0 && rt.LatencyPercentile < 1 {
+			latency, err := e.query(ctx, fmt.Sprintf(
+				`histogram_quantile(%g, sum(rate(%s{%s}[%s])) by (le))`,
+				rt.LatencyPercentile, buckets, selector, rng))
+			if err != nil {
+				return nil, fmt.Errorf("latency %s %s: %w", rt.Method, rt.Route, err)
+			}
+			if math.IsNaN(latency) {
+				continue
+			}
+			// Like the reporter, estimate violations from how far the
+			// percentile exceeds the target, capped at the budget.
+			budget := 1 - rt.LatencyPercentile
+			violations := 0.0
+			if latency > rt.LatencyTargetSeconds {
+				violations = min((latency-rt.LatencyTargetSeconds)/rt.LatencyTargetSeconds*0.1, budget)
+			}
+			eval.SLOs = append(eval.SLOs, newSLOResult(
+				fmt.Sprintf("Latency (p%g) %s %s", rt.LatencyPercentile*100, rt.Method, rt.Route),
+				"latency", rt, latency, rt.LatencyTargetSeconds, budget, violations/budget))
+		}
+	}
+	return eval, nil
+}
+
+func newSLOResult(name, kind string, rt sloManifestRoute, value, target, budget, spent float64) sloResult {
+	status := "healthy"
+	switch {
+	case spent >= 1:
+		status = "breached"
+	case spent >= 0.8:
+		status = "warning"
+	}
+	return sloResult{
+		SLI:              name,
+		Kind:             kind,
+		Method:           rt.Method,
+		Route:            rt.Route,
+		CurrentValue:     value,
+		Target:           target,
+		ErrorBudget:      budget,
+		ErrorBudgetSpent: spent,
+		ErrorBudgetLeft:  1 - spent,
+		BurnRate:         spent,
+		Status:           status,
+	}
+}
+
+// query runs an instant query and returns the value of its first series,
+// or NaN when there is none.
+func (e *sloEvaluator) query(ctx context.Context, q string) (float64, error) {
+	params := url.Values{"query": {q}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.prometheusURL+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("prometheus returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Data struct {
+			Result []struct {
+				Value []any `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if len(result.Data.Result) == 0 || len(result.Data.Result[0].Value) != 2 {
+		// No series: the route has had no traffic
+		return math.NaN(), nil
+	}
+	s, _ := result.Data.Result[0].Value[1].(string)
+	return strconv.ParseFloat(s, 64)
+}
+
+// latest returns the latest evaluation, or nil before the first one or
+// when e is nil because SLO_PROMETHEUS_URL is unset.
+func (e *sloEvaluator) latest() *sloEvaluation {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.last
+}
+
+// ServeHTTP serves the latest evaluation on /v1/slo.
+func (e *sloEvaluator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	last := e.latest()
+	if last == nil {
+		writeRetryableError(r.Context(), w, http.StatusServiceUnavailable, errorClassDependency, dependencyRetryAfter, "slo evaluation not ready")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(last)
+}
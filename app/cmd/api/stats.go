@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"codigo/internal/storage"
+)
+
+type jobCost struct {
+	Tenant      string  `json:"tenant"`
+	Type        string  `json:"type"`
+	Jobs        int64   `json:"jobs"`
+	WallSeconds float64 `json:"wall_seconds"`
+	CPUSeconds  float64 `json:"cpu_seconds"`
+}
+
+// jobCosts reports the wall and CPU time the caller's jobs spent per job
+// type over the last ?days= UTC days, today included (default 30), for
+// chargeback of the shared worker fleet. It reads the daily totals
+// runCostRollup keeps in job_costs, at most days rows per type through the
+// primary key, so it doesn't grow with the number of attempts; updated_at
+// tells how recent they are. Like every job endpoint it only covers the
+// caller's tenant.
+func (s *Server) jobCosts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	scope := s.scopeFrom(ctx)
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 366 {
+			writeError(ctx, w, http.StatusBadRequest, "days must be between 1 and 366")
+			return
+		}
+		days = n
+	}
+
+	qctx, cancel := storage.WithQuery(ctx, "job_costs")
+	defer cancel()
+	rows, err := s.bgdb.Query(qctx, `
+		SELECT tenant, type, sum(jobs), sum(wall_seconds), sum(cpu_seconds)
+		FROM job_costs
+		WHERE tenant = $1 AND day > (now() AT TIME ZONE 'UTC')::date - $2::int
+		GROUP BY tenant, type
+		ORDER BY sum(cpu_seconds) DESC, sum(wall_seconds) DESC`, scope.Tenant, days)
+	if err != nil {
+		scope.Logger.Error("database error - job costs", zap.Error(err))
+		writeError(ctx, w, http.StatusInternalServerError, "db error")
+		return
+	}
+	defer rows.Close()
+
+	costs := []jobCost{}
+	for rows.Next() {
+		var c jobCost
+		if err := rows.Scan(&c.Tenant, &c.Type, &c.Jobs, &c.WallSeconds, &c.CPUSeconds); err != nil {
+			scope.Logger.Error("database error - scan job costs", zap.Error(err))
+			writeError(ctx, w, http.StatusInternalServerError, "db error")
+			return
+		}
+		costs = append(costs, c)
+	}
+	if err := rows.Err(); err != nil {
+		scope.Logger.Error("database error - job costs", zap.Error(err))
+		writeError(ctx, w, http.StatusInternalServerError, "db error")
+		return
+	}
+	var updatedAt *time.Time
+	if err := s.bgdb.QueryRow(qctx, `SELECT rolled_up_at FROM job_costs_rollup`).Scan(&updatedAt); err != nil {
+		scope.Logger.Error("database error - job costs rollup", zap.Error(err))
+		writeError(ctx, w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"days": days, "costs": costs, "updated_at": updatedAt})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Overall and component statuses reported on /status, from best to worst.
+// The names and the response shape are a public contract for status pages:
+// fields may be added, never renamed or removed.
+const (
+	statusOperational = "operational"
+	statusMaintenance = "maintenance"
+	statusDegraded    = "degraded"
+	statusMajorOutage = "major_outage"
+)
+
+// statusCacheTTL bounds how often /status probes the dependencies, however
+// many status pages poll it.
+const statusCacheTTL = 10 * time.Second
+
+type componentStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// sloSummary is the worst embedded SLO result, without per-route details.
+type sloSummary struct {
+	Status      string    `json:"status"` // healthy, warning or breached
+	WindowDays  int       `json:"window_days"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+type statusResponse struct {
+	Status     string            `json:"status"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Components []componentStatus `json:"components"`
+	// SLO is omitted without SLO_PROMETHEUS_URL or before the first run.
+	SLO *sloSummary `json:"slo,omitempty"`
+	// MaintenanceUntil is the planned end of an ongoing window, when known.
+	MaintenanceUntil *time.Time `json:"maintenance_until,omitempty"`
+}
+
+// statusPage serves /status for external status pages. Unlike /admin/topology
+// it reveals no targets, errors or per-route numbers.
+type statusPage struct {
+	s *Server
+
+	mu   sync.Mutex
+	last *statusResponse
+}
+
+func (p *statusPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	if p.last == nil || time.Since(p.last.UpdatedAt) > statusCacheTTL {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		p.last = p.s.systemStatus(ctx)
+		cancel()
+	}
+	resp := p.last
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=10")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// systemStatus derives the overall status from the dependencies, the
+// worker fleet, maintenance mode and the latest SLO evaluation.
+func (s *Server) systemStatus(ctx context.Context) *statusResponse {
+	resp := &statusResponse{Status: statusOperational, UpdatedAt: time.Now().UTC()}
+	worsen := func(status string) {
+		if statusRank(status) > statusRank(resp.Status) {
+			resp.Status = status
+		}
+	}
+	component := func(name string, healthy bool, unhealthy string) {
+		status := statusOperational
+		if !healthy {
+			status = unhealthy
+		}
+		resp.Components = append(resp.Components, componentStatus{Name: name, Status: status})
+		worsen(status)
+	}
+
+	component("api", s.warm.Load(), statusDegraded)
+	component("database", s.postgresStatus(ctx).Healthy, statusMajorOutage)
+	component("queue", s.natsStatus().Healthy, statusMajorOutage)
+	component("job_processing", len(s.workers.live()) > 0, statusDegraded)
+
+	if m := s.maintenance.current(); m.Enabled {
+		worsen(statusMaintenance)
+		resp.MaintenanceUntil = m.Until
+	}
+	if eval := s.slo.latest(); eval != nil {
+		summary := &sloSummary{Status: "healthy", WindowDays: eval.WindowDays, EvaluatedAt: eval.EvaluatedAt}
+		for _, r := range eval.SLOs {
+			if r.Status == "breached" || (r.Status == "warning" && summary.Status == "healthy") {
+				summary.Status = r.Status
+			}
+		}
+		if summary.Status == "breached" {
+			worsen(statusDegraded)
+		}
+		resp.SLO = summary
+	}
+	return resp
+}
+
+func statusRank(status string) int {
+	switch status {
+	case statusMaintenance:
+		return 1
+	case statusDegraded:
+		return 2
+	case statusMajorOutage:
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Timestamps in responses are RFC 3339 (time.Time's JSON encoding) in UTC.
+// Endpoints people read directly also take ?tz= with an IANA zone name such
+// as Europe/Madrid to render them in local time with the matching offset.
+
+var errInvalidTimeZone = errors.New("tz must be an IANA time zone name such as Europe/Madrid")
+
+// displayLocation returns the zone named by ?tz=, or UTC without one.
+func displayLocation(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, errInvalidTimeZone
+	}
+	return loc, nil
+}
+
+// inLocation returns t in loc, or nil when t is nil.
+func inLocation(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.In(loc)
+	return &v
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+)
+
+// topologyProbeTimeout bounds each dependency check so one hung dependency
+// doesn't stall the whole report.
+const topologyProbeTimeout = 2 * time.Second
+
+type dependencyStatus struct {
+	Name      string            `json:"name"`
+	Kind      string            `json:"kind"`
+	Target    string            `json:"target,omitempty"`
+	Healthy   bool              `json:"healthy"`
+	LatencyMs float64           `json:"latency_ms,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+type topologyReport struct {
+	Service      string             `json:"service"`
+	Region       string             `json:"region,omitempty"`
+	Dependencies []dependencyStatus `json:"dependencies"`
+}
+
+// topology reports the API's live dependencies with health and latency, for
+// incident triage.
+func (s *Server) topology(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	report := topologyReport{
+		Service: config.String("SERVICE_NAME", "codigo-api"),
+		Region:  s.region,
+		Dependencies: []dependencyStatus{
+			s.postgresStatus(ctx),
+			s.natsStatus(),
+			otelCollectorStatus(ctx),
+		},
+	}
+	for _, d := range report.Dependencies {
+		if !d.Healthy {
+			s.logger.Warn("dependency unhealthy",
+				zap.String("dependency", d.Name),
+				zap.String("error", d.Error))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) postgresStatus(ctx context.Context) dependencyStatus {
+	cfg := s.db.Config().ConnConfig
+	stat := s.db.Stat()
+	bgStat := s.bgdb.Stat()
+	d := dependencyStatus{
+		Name:   "postgres",
+		Kind:   "database",
+		Target: net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		Details: map[string]string{
+			"database":       cfg.Database,
+			"acquired_conns": strconv.Itoa(int(stat.AcquiredConns())),
+			"max_conns":      strconv.Itoa(int(stat.MaxConns())),
+
+			"background_acquired_conns": strconv.Itoa(int(bgStat.AcquiredConns())),
+			"background_max_conns":      strconv.Itoa(int(bgStat.MaxConns())),
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, topologyProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	err := s.db.Ping(ctx)
+	d.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	d.Healthy = err == nil
+	if err != nil {
+		d.Error = err.Error()
+	}
+	return d
+}
+
+func (s *Server) natsStatus() dependencyStatus {
+	d := dependencyStatus{
+		Name:   "nats",
+		Kind:   "broker",
+		Target: s.nats.ConnectedUrlRedacted(),
+		Details: map[string]string{
+			"status":    s.nats.Status().String(),
+			"cluster":   s.nats.ConnectedClusterName(),
+			"server_id": s.nats.ConnectedServerId(),
+		},
+	}
+	if !s.nats.IsConnected() {
+		d.Error = "not connected"
+		return d
+	}
+	rtt, err := s.nats.RTT()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Healthy = true
+	d.LatencyMs = float64(rtt.Microseconds()) / 1000
+	return d
+}
+
+// otelCollectorStatus checks that the OTLP endpoint accepts TCP connections;
+// export failures themselves show up in codigo_otel_spans_dropped_total
+// with reason export_failed.
+func otelCollectorStatus(ctx context.Context) dependencyStatus {
+	d := dependencyStatus{Name: "otel-collector", Kind: "telemetry"}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		d.Healthy = true
+		d.Details = map[string]string{"status": "disabled"}
+		return d
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	d.Target = host
+
+	ctx, cancel := context.WithTimeout(ctx, topologyProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	d.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	conn.Close()
+	d.Healthy = true
+	return d
+}
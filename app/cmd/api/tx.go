@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"codigo/internal/storage"
+)
+
+// WithTx runs fn in a retried transaction on the interactive pool; see
+// storage.WithTx.
+func (s *Server) WithTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	return storage.WithTx(ctx, s.db, s.txDuration(), fn)
+}
+
+// WithBackgroundTx is WithTx on the background pool, for maintenance work
+// that must not hold connections user-facing requests need.
+func (s *Server) WithBackgroundTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	return storage.WithTx(ctx, s.bgdb, s.txDuration(), fn)
+}
+
+// txDuration is db_tx_duration_seconds for the API's transactions.
+func (s *Server) txDuration() prometheus.ObserverVec {
+	return prom.DBTxDuration.MustCurryWith(prometheus.Labels{"service": s.serviceName})
+}
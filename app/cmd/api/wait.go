@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"codigo/internal/errs"
+	"codigo/internal/storage"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 60 * time.Second
+	maxWaitPoll        = time.Second
+)
+
+type jobWaitResponse struct {
+	JobID    string `json:"job_id"`
+	Status   string `json:"status"`
+	Terminal bool   `json:"terminal"`
+
+	CreatedAt time.Time `json:"created_at"`
+	// StartedAt is when the first attempt started and CompletedAt when the
+	// job reached a terminal status; each is omitted until it happens.
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// jobRecord is a job's status and timeline.
+type jobRecord struct {
+	Status      string
+	CreatedAt   time.Time
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+}
+
+// terminalJobStatus reports whether a job in status will not change again.
+func terminalJobStatus(status string) bool {
+	return status == "done" || status == "failed" || status == "expired"
+}
+
+// waitJob long-polls until the job reaches a terminal state or ?timeout=
+// (default 30s, at most 60s) passes, for clients behind proxies that break
+// streaming responses. On timeout the current status is returned with
+// terminal=false and the client is expected to call again.
+func (s *Server) waitJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	scope := s.scopeFrom(ctx)
+	loc, err := displayLocation(r)
+	if err != nil {
+		writeError(ctx, w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	timeout := defaultWaitTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 || d > maxWaitTimeout {
+			writeError(ctx, w, http.StatusBadRequest, "timeout must be a duration between 0s and 60s")
+			return
+		}
+		timeout = d
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	// Poll with backoff: quick jobs answer fast, long ones cost at most one
+	// query per second per waiting client.
+	poll := 100 * time.Millisecond
+	for {
+		job, err := s.jobStatus(ctx, id, scope.Tenant)
+		if errs.Is(err, errs.NotFound) {
+			writeError(ctx, w, http.StatusNotFound, "job not found")
+			return
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// Client went away
+				return
+			}
+			scope.Logger.Error("database error - job status",
+				zap.String("job_id", id),
+				zap.Error(err))
+			writeDomainError(ctx, w, err, "db error")
+			return
+		}
+
+		done := terminalJobStatus(job.Status)
+		if !done {
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline.C:
+				done = true
+			case <-time.After(poll):
+			}
+		}
+		if done {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(jobWaitResponse{
+				JobID:       id,
+				Status:      job.Status,
+				Terminal:    terminalJobStatus(job.Status),
+				CreatedAt:   job.CreatedAt.In(loc),
+				StartedAt:   inLocation(job.StartedAt, loc),
+				CompletedAt: inLocation(job.CompletedAt, loc),
+			})
+			return
+		}
+		poll = min(2*poll, maxWaitPoll)
+	}
+}
+
+// jobStatusSQL looks a job up in the hot table, then in the archive, with
+// its first attempt start and last attempt finish. It is also prepared
+// during warmup.
+const jobStatusSQL = `
+	SELECT j.status, j.created_at,
+		(SELECT min(started_at) FROM job_attempts WHERE job_id = j.id),
+		(SELECT max(finished_at) FROM job_attempts WHERE job_id = j.id)
+	FROM (
+		SELECT id, status, created_at FROM jobs WHERE id = $1 AND tenant = $2
+		UNION ALL
+		SELECT id, status, created_at FROM jobs_history WHERE id = $1 AND tenant = $2
+		LIMIT 1
+	) j`
+
+// jobStatus returns the tenant's job status and timeline, looking in the
+// archive for jobs the janitor has already moved.
+func (s *Server) jobStatus(ctx context.Context, id, tenant string) (jobRecord, error) {
+	qctx, cancel := storage.WithQuery(ctx, "job_status")
+	defer cancel()
+	var job jobRecord
+	err := s.db.QueryRow(qctx, jobStatusSQL, id, tenant).Scan(&job.Status, &job.CreatedAt, &job.StartedAt, &job.CompletedAt)
+	if !terminalJobStatus(job.Status) {
+		job.CompletedAt = nil
+	}
+	return job, err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// hotStatements are prepared on the warmed connections. pgx keys prepared
+// statements by their SQL, so later queries with the same text reuse them.
+var hotStatements = []string{insertJobSQL, existingJobSQL, archivedJobSQL, jobStatusSQL}
+
+// warmup opens the interactive pool's minimum connections, prepares the hot
+// statements on them and round-trips to NATS, then marks the server ready.
+// Failures are logged and don't block readiness: /readyz still checks both
+// dependencies.
+func (s *Server) warmup(ctx context.Context) {
+	start := time.Now()
+	n := max(int(s.db.Config().MinConns), 1)
+	conns := make([]*pgxpool.Conn, 0, n)
+	defer func() {
+		for _, c := range conns {
+			c.Release()
+		}
+	}()
+	// Hold every connection until all are open so the pool can't hand the
+	// same one back.
+	for range n {
+		c, err := s.db.Acquire(ctx)
+		if err != nil {
+			s.logger.Warn("warmup failed to open database connection", zap.Error(err))
+			break
+		}
+		conns = append(conns, c)
+		for _, sql := range hotStatements {
+			if _, err := c.Conn().Prepare(ctx, sql, sql); err != nil {
+				s.logger.Warn("warmup failed to prepare statement", zap.String("sql", sql), zap.Error(err))
+			}
+		}
+	}
+
+	// A round trip confirms the connection and lets the client learn the
+	// cluster's other servers before the first publish.
+	if err := s.nats.FlushWithContext(ctx); err != nil {
+		s.logger.Warn("warmup failed to reach nats", zap.Error(err))
+	}
+
+	s.warm.Store(true)
+	s.logger.Info("warmup finished",
+		zap.Duration("duration", time.Since(start)),
+		zap.Int("db_connections", len(conns)),
+		zap.Int("statements", len(hotStatements)),
+		zap.Strings("nats_servers", s.nats.Servers()))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"codigo/internal/jobs"
+)
+
+// workerStatus is a worker's last heartbeat and when it arrived.
+type workerStatus struct {
+	jobs.Heartbeat
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// workerRegistry tracks live workers from their heartbeats. Each API replica
+// subscribes without a queue group, so every replica sees the whole fleet.
+type workerRegistry struct {
+	staleAfter time.Duration
+	logger     *zap.Logger
+
+	mu      sync.Mutex
+	workers map[string]workerStatus
+}
+
+func newWorkerRegistry(staleAfter time.Duration, logger *zap.Logger) *workerRegistry {
+	return &workerRegistry{
+		staleAfter: staleAfter,
+		logger:     logger,
+		workers:    make(map[string]workerStatus),
+	}
+}
+
+func (reg *workerRegistry) observe(m *nats.Msg) {
+	var hb jobs.Heartbeat
+	if err := json.Unmarshal(m.Data, &hb); err != nil || hb.Instance == "" {
+		reg.logger.Warn("invalid worker heartbeat", zap.Error(err))
+		return
+	}
+	reg.mu.Lock()
+	reg.workers[hb.Instance] = workerStatus{Heartbeat: hb, LastSeen: time.Now()}
+	reg.mu.Unlock()
+}
+
+// live returns workers seen within staleAfter, forgetting the rest.
+func (reg *workerRegistry) live() []workerStatus {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	live := make([]workerStatus, 0, len(reg.workers))
+	for id, ws := range reg.workers {
+		if time.Since(ws.LastSeen) > reg.staleAfter {
+			delete(reg.workers, id)
+			continue
+		}
+		live = append(live, ws)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].Instance < live[j].Instance })
+	return live
+}
+
+// listWorkers shows live workers with their versions and in-flight jobs, for
+// fleet visibility during rollouts.
+func (s *Server) listWorkers(w http.ResponseWriter, r *http.Request) {
+	loc, err := displayLocation(r)
+	if err != nil {
+		writeError(r.Context(), w, http.StatusBadRequest, err.Error())
+		return
+	}
+	workers := s.workers.live()
+
+	versions := make(map[string]int)
+	var inFlight int64
+	for i, ws := range workers {
+		versions[ws.Version]++
+		inFlight += ws.InFlight
+		// Heartbeats carry each worker's local offset
+		workers[i].StartedAt = ws.StartedAt.In(loc)
+		workers[i].SentAt = ws.SentAt.In(loc)
+		workers[i].LastSeen = ws.LastSeen.In(loc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"workers":   workers,
+		"versions":  versions,
+		"in_flight": inFlight,
+	})
+}
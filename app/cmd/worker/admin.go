@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// adminKeyHeader carries the key for admin-only endpoints, as on the API.
+const adminKeyHeader = "X-Admin-Key"
+
+// adminKeys are the keys allowed to use the worker's admin-only endpoints,
+// loaded from the same comma-separated ADMIN_API_KEYS as the API. With none
+// configured every check fails.
+type adminKeys [][]byte
+
+func loadAdminKeys() adminKeys {
+	var keys adminKeys
+	for _, k := range strings.Split(os.Getenv("ADMIN_API_KEYS"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, []byte(k))
+		}
+	}
+	return keys
+}
+
+// require rejects requests without a valid admin key.
+func (k adminKeys) require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := []byte(r.Header.Get(adminKeyHeader))
+		ok := false
+		for _, key := range k {
+			if len(presented) > 0 && subtle.ConstantTimeCompare(presented, key) == 1 {
+				ok = true
+			}
+		}
+		if !ok {
+			http.Error(w, "admin key required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
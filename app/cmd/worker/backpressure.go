@@ -0,0 +1,158 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// concurrencyLimiter caps how many of a queue's goroutines take jobs at
+// once. The limit moves between 1 and the configured concurrency; the
+// goroutines above it stop pulling from the dispatcher, which admits
+// proportionally fewer jobs per tenant and refuses the rest back to the
+// API, so the slowdown reaches the clients instead of piling up here.
+type concurrencyLimiter struct {
+	serviceName string
+	queue       string
+	max         int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func newConcurrencyLimiter(serviceName, queue string, max int) *concurrencyLimiter {
+	l := &concurrencyLimiter{serviceName: serviceName, queue: queue, max: max, limit: max}
+	l.cond = sync.NewCond(&l.mu)
+	prom.AdaptiveConcurrency.WithLabelValues(serviceName, queue).Set(float64(max))
+	return l
+}
+
+// acquire blocks until a slot under the current limit is free.
+func (l *concurrencyLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// share returns n scaled by the current limit's share of the configured
+// concurrency, at least 1.
+func (l *concurrencyLimiter) share(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return max(1, n*l.limit/l.max)
+}
+
+// current returns the limit jobs run under now.
+func (l *concurrencyLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
+
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	l.cond.Broadcast()
+}
+
+// decrease cuts the limit by a quarter; jobs already running finish.
+func (l *concurrencyLimiter) decrease() {
+	l.setLimit(func(limit int) int { return max(1, limit*3/4) })
+}
+
+// increase raises the limit by one.
+func (l *concurrencyLimiter) increase() {
+	l.setLimit(func(limit int) int { return min(l.max, limit+1) })
+}
+
+func (l *concurrencyLimiter) setLimit(next func(int) int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := next(l.limit)
+	if limit == l.limit {
+		return
+	}
+	l.limit = limit
+	prom.AdaptiveConcurrency.WithLabelValues(l.serviceName, l.queue).Set(float64(limit))
+	l.cond.Broadcast()
+}
+
+// dbBackpressure lowers the concurrency of every queue while the Postgres
+// pool is saturated and raises it back once acquisitions are fast again.
+type dbBackpressure struct {
+	db        *pgxpool.Pool
+	threshold time.Duration
+	logger    *zap.Logger
+
+	mu       sync.Mutex
+	limiters []*concurrencyLimiter
+}
+
+// backpressure is nil unless the worker records results in Postgres and
+// WORKER_DB_WAIT_THRESHOLD is positive.
+var backpressure *dbBackpressure
+
+func (b *dbBackpressure) register(l *concurrencyLimiter) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.limiters = append(b.limiters, l)
+	b.mu.Unlock()
+}
+
+// run samples the pool every interval. The pool counts as saturated when the
+// average acquisition waited longer than threshold, or when acquisitions had
+// to wait for a connection with every connection in use. It counts as
+// healthy when no acquisition waited and the average stayed under half the
+// threshold; in between the limits are left alone.
+func (b *dbBackpressure) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := b.db.Stat()
+	for range ticker.C {
+		stats := b.db.Stat()
+		acquires := stats.AcquireCount() - prev.AcquireCount()
+		waited := stats.EmptyAcquireCount() - prev.EmptyAcquireCount()
+		var avgWait time.Duration
+		if acquires > 0 {
+			avgWait = (stats.AcquireDuration() - prev.AcquireDuration()) / time.Duration(acquires)
+		}
+		prev = stats
+
+		saturated := avgWait > b.threshold || (waited > 0 && stats.AcquiredConns() >= stats.MaxConns())
+		healthy := waited == 0 && avgWait < b.threshold/2
+
+		b.mu.Lock()
+		for _, l := range b.limiters {
+			switch {
+			case saturated:
+				l.decrease()
+			case healthy:
+				l.increase()
+			}
+		}
+		b.mu.Unlock()
+		if saturated {
+			b.logger.Warn("database pool saturated, reducing concurrency",
+				zap.Duration("avg_acquire_wait", avgWait),
+				zap.Int64("waited_acquires", waited),
+				zap.Int32("acquired_conns", stats.AcquiredConns()),
+				zap.Int32("max_conns", stats.MaxConns()))
+		}
+	}
+}
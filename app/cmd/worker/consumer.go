@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// queueConsumer owns a queue's subscriptions so consumption can stop and
+// start again: maintenance windows pause it and a stopping worker closes
+// it. While it is stopped, dispatches of its subjects reach other replicas
+// or, with none left, get no responders and the API refuses the jobs,
+// instead of this worker taking jobs it won't run.
+//
+// Stopping drains the subscriptions rather than unsubscribing, so messages
+// still in the client's pending buffer reach the dispatcher instead of
+// being dropped.
+type queueConsumer struct {
+	nc     *nats.Conn
+	queue  queueConfig
+	handle nats.MsgHandler
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	subs     []*nats.Subscription // open subscriptions, nil while stopped
+	stopping []*nats.Subscription // subscriptions still draining
+	closed   bool
+}
+
+func newQueueConsumer(nc *nats.Conn, q queueConfig, handle nats.MsgHandler, logger *zap.Logger) *queueConsumer {
+	return &queueConsumer{nc: nc, queue: q, handle: handle, logger: logger}
+}
+
+// subscribe starts consuming, unless the consumer is already consuming or
+// closed.
+func (c *queueConsumer) subscribe() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.subs != nil {
+		return nil
+	}
+	subs := make([]*nats.Subscription, 0, len(c.queue.Subjects))
+	for _, subject := range c.queue.Subjects {
+		sub, err := c.nc.QueueSubscribe(subject, c.queue.QueueGroup, c.handle)
+		if err != nil {
+			for _, sub := range subs {
+				sub.Unsubscribe()
+			}
+			return fmt.Errorf("failed to subscribe to jobs of queue %s on %s: %w", c.queue.Name, subject, err)
+		}
+		subs = append(subs, sub)
+	}
+	c.subs = subs
+	return nil
+}
+
+// pause stops consuming until the next subscribe.
+func (c *queueConsumer) pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stop()
+}
+
+// close stops consuming for good.
+func (c *queueConsumer) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.stop()
+}
+
+func (c *queueConsumer) stop() {
+	for _, sub := range c.subs {
+		if err := sub.Drain(); err != nil {
+			c.logger.Warn("failed to drain job subscription", zap.String("subject", sub.Subject), zap.Error(err))
+		}
+	}
+	c.stopping = append(c.stopping, c.subs...)
+	c.subs = nil
+}
+
+// drained reports whether the consumer is stopped and every subscription
+// has handed over its pending messages and closed.
+func (c *queueConsumer) drained() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subs != nil {
+		return false
+	}
+	open := c.stopping[:0]
+	for _, sub := range c.stopping {
+		if sub.IsValid() {
+			open = append(open, sub)
+		}
+	}
+	c.stopping = open
+	return len(open) == 0
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// threadCPUTime returns the user+system CPU time consumed by the calling OS
+// thread. Callers must hold runtime.LockOSThread for the difference between
+// two readings to be attributable to one goroutine.
+func threadCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_THREAD, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+// threadCPUTime is not available outside Linux; jobs report zero CPU time.
+func threadCPUTime() time.Duration {
+	return 0
+}
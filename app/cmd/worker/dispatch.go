@@ -0,0 +1,170 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// bufferedJob is a received job with the tenant label its metrics use,
+// fixed at enqueue. The depth gauge is kept as a handle so it goes back
+// down on the series it went up on, even if that tenant's series was
+// deleted meanwhile.
+type bufferedJob struct {
+	msg   *nats.Msg
+	label string
+	depth prometheus.Gauge
+}
+
+// fairDispatcher buffers received jobs per tenant and hands them out in
+// round-robin tenant order, so a tenant that enqueues a large burst only
+// gets its proportional share of worker capacity instead of starving
+// everyone queued behind it.
+type fairDispatcher struct {
+	serviceName  string
+	queue        string
+	maxPerTenant int
+	// limiter scales maxPerTenant down while the queue's concurrency is
+	// cut, so a slowed worker refuses jobs instead of hoarding them.
+	limiter *concurrencyLimiter
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[string][]bufferedJob
+	ring   []string // tenants with buffered jobs, in service order
+	pos    int
+	taken  int // jobs handed out by next and not yet finished
+}
+
+func newFairDispatcher(serviceName, queue string, maxPerTenant int, limiter *concurrencyLimiter) *fairDispatcher {
+	d := &fairDispatcher{
+		serviceName:  serviceName,
+		queue:        queue,
+		maxPerTenant: maxPerTenant,
+		limiter:      limiter,
+		queues:       make(map[string][]bufferedJob),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// enqueue buffers m for tenant and reports whether it was accepted. When the
+// tenant's buffer is full, at maxPerTenant scaled by the limiter's share, a
+// job the publisher is waiting on is refused, and the publisher hears so
+// from the reply, rather than blocking: the subscription callback is shared
+// by every tenant, so blocking it would stall them all behind one tenant's
+// burst. A job without a reply subject is buffered past the limit, since
+// nobody would learn it was refused.
+func (d *fairDispatcher) enqueue(tenant string, m *nats.Msg) bool {
+	label := metricDims.Tenant(tenant)
+	limit := d.limiter.share(d.maxPerTenant)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q, ok := d.queues[tenant]
+	if len(q) >= limit && m.Reply != "" {
+		prom.TenantJobsRefused.WithLabelValues(d.serviceName, d.queue, label).Inc()
+		return false
+	}
+	if !ok {
+		d.ring = append(d.ring, tenant)
+	}
+	depth := prom.TenantQueueDepth.WithLabelValues(d.serviceName, d.queue, label)
+	depth.Inc()
+	d.queues[tenant] = append(q, bufferedJob{msg: m, label: label, depth: depth})
+	d.cond.Broadcast()
+	return true
+}
+
+// next blocks until a job is buffered and returns the oldest job of the next
+// tenant in round-robin order.
+func (d *fairDispatcher) next() *nats.Msg {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(d.ring) == 0 {
+		d.cond.Wait()
+	}
+	if d.pos >= len(d.ring) {
+		d.pos = 0
+	}
+	tenant := d.ring[d.pos]
+	q := d.queues[tenant]
+	job := q[0]
+	q[0] = bufferedJob{}
+	q = q[1:]
+
+	if len(q) == 0 {
+		// Drop the tenant from the ring; pos now points at the next tenant.
+		delete(d.queues, tenant)
+		d.ring = append(d.ring[:d.pos], d.ring[d.pos+1:]...)
+	} else {
+		d.queues[tenant] = q
+		d.pos++
+	}
+
+	job.depth.Dec()
+	prom.TenantJobsDispatched.WithLabelValues(d.serviceName, d.queue, job.label).Inc()
+	d.taken++
+	return job.msg
+}
+
+// finish marks a job returned by next as processed.
+func (d *fairDispatcher) finish() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.taken--
+}
+
+// pending returns the number of jobs buffered across all tenants or
+// handed out and not yet finished.
+func (d *fairDispatcher) pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := d.taken
+	for _, q := range d.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// load returns the share of the queue's capacity in use: its buffered and
+// running jobs over the limiter's current limit plus one tenant's buffer at
+// that limit. It passes 1 once jobs outnumber it. Running jobs are counted
+// here rather than by the limiter, whose slots are also held by goroutines
+// waiting in next.
+func (d *fairDispatcher) load() float64 {
+	capacity := d.limiter.current() + d.limiter.share(d.maxPerTenant)
+	return float64(d.pending()) / float64(capacity)
+}
+
+// running returns the number of jobs handed out and not yet finished.
+func (d *fairDispatcher) running() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.taken
+}
+
+// abandon empties the buffers and returns the jobs they held, oldest first
+// per tenant.
+func (d *fairDispatcher) abandon() []*nats.Msg {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var msgs []*nats.Msg
+	for _, tenant := range d.ring {
+		for _, job := range d.queues[tenant] {
+			job.depth.Dec()
+			msgs = append(msgs, job.msg)
+		}
+		delete(d.queues, tenant)
+	}
+	d.ring = nil
+	d.pos = 0
+	return msgs
+}
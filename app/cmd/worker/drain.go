@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/contract"
+	"codigo/internal/jobs"
+)
+
+// drainTimeout bounds how long a stopping worker keeps processing the jobs
+// it already received. It must fit in SHUTDOWN_TIMEOUT with room left to
+// close the database pool and the NATS connection.
+var drainTimeout = config.Duration("WORKER_DRAIN_TIMEOUT", 30*time.Second)
+
+// drainPoll is how often a draining worker checks whether it is done.
+const drainPoll = 100 * time.Millisecond
+
+// drain stops a worker from receiving new jobs and waits, up to
+// drainTimeout or ctx's deadline, for the ones it holds: jobs buffered in
+// the dispatchers and jobs running in processJob. During maintenance the
+// buffered jobs can't start, so it only waits for the running ones. Core
+// NATS doesn't redeliver, so buffered jobs still left are recorded as
+// failed, which tells their clients to resubmit them, rather than left
+// queued forever. The caller closes the database pool and the connection
+// afterwards.
+func drain(ctx context.Context, consumers []*queueConsumer, dispatchers []*fairDispatcher, maintenance *maintenanceGate, recorder resultRecorder, serviceName string, logger *zap.Logger) {
+	start := time.Now()
+	logger.Info("worker draining",
+		zap.Int("pending", pending(dispatchers)),
+		zap.Duration("timeout", drainTimeout))
+	for _, c := range consumers {
+		c.close()
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, drainTimeout)
+	defer cancel()
+	switch {
+	case waitDrained(waitCtx, consumers, dispatchers, maintenance):
+		logger.Info("worker drained", zap.Duration("elapsed", time.Since(start)))
+		return
+	case waitCtx.Err() != nil:
+		// Running jobs left now are lost; log them so they can be found and
+		// resubmitted.
+		logger.Warn("worker drain timed out",
+			zap.Duration("elapsed", time.Since(start)),
+			zap.Int("pending", pending(dispatchers)),
+			zap.Any("running", runningJobs.snapshot()))
+	default:
+		logger.Warn("worker drained with jobs held by maintenance",
+			zap.Duration("elapsed", time.Since(start)),
+			zap.Int("pending", pending(dispatchers)))
+	}
+	for _, d := range dispatchers {
+		for _, m := range d.abandon() {
+			// Only the ID matters here, which a body that can't be opened
+			// may still leave in the headers.
+			job, _ := contract.Decode(m, payloadKeys)
+			failUnstarted(ctx, job, "worker stopped before running the job", recorder, serviceName, logger)
+		}
+	}
+}
+
+// waitDrained waits until the consumers are drained and no job is pending,
+// and reports whether that happened. It gives up when ctx is done, or when
+// only jobs held by maintenance are left.
+func waitDrained(ctx context.Context, consumers []*queueConsumer, dispatchers []*fairDispatcher, maintenance *maintenanceGate) bool {
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for {
+		if drained(consumers) {
+			if pending(dispatchers) == 0 {
+				return true
+			}
+			if maintenance.enabled() && running(dispatchers) == 0 {
+				return false
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// failUnstarted records a received job that was never run as failed. A job
+// whose ID couldn't be read, from a publisher that predates
+// queue.JobIDHeader and with a body that can't be opened, is only logged.
+func failUnstarted(ctx context.Context, job contract.Job, reason string, recorder resultRecorder, serviceName string, logger *zap.Logger) {
+	dimTenant, dimType := metricDims.Labels(job.Tenant, job.Type)
+	prom.JobsProcessed.WithLabelValues(serviceName, "error", dimTenant, dimType).Inc()
+	if job.ID == "" {
+		logger.Error("job failed without running and can't be recorded",
+			zap.String("tenant", job.Tenant),
+			zap.String("type", job.Type),
+			zap.String("reason", reason))
+		return
+	}
+	now := time.Now()
+	res := jobs.Result{
+		JobID:      job.ID,
+		Tenant:     job.Tenant,
+		Type:       job.Type,
+		Status:     "failed",
+		Error:      reason,
+		Worker:     instanceID,
+		StartedAt:  now,
+		FinishedAt: now,
+	}
+	err := recorder.Record(ctx, res)
+	wait, _ := queueWait(job, now)
+	completions.export(res, wait, errors.New(reason))
+	if err != nil {
+		logger.Error("failed to record job result",
+			zap.String("job_id", res.JobID),
+			zap.String("reason", reason),
+			zap.Error(err))
+		return
+	}
+	logger.Warn("job failed without running",
+		zap.String("job_id", res.JobID),
+		zap.String("reason", reason))
+}
+
+// drained reports whether every consumer has stopped and handed over its
+// pending messages.
+func drained(consumers []*queueConsumer) bool {
+	for _, c := range consumers {
+		if !c.drained() {
+			return false
+		}
+	}
+	return true
+}
+
+// running is the number of jobs being processed.
+func running(dispatchers []*fairDispatcher) int {
+	n := 0
+	for _, d := range dispatchers {
+		n += d.running()
+	}
+	return n
+}
+
+// pending is the number of received jobs not yet processed, buffered or
+// running.
+func pending(dispatchers []*fairDispatcher) int {
+	n := 0
+	for _, d := range dispatchers {
+		n += d.pending()
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"codigo/internal/errs"
+	"codigo/internal/jobs"
+)
+
+// completionRecord is the compact per-job summary exported for offline
+// analytics. Field names are a contract with the warehouse loaders; add
+// fields, don't rename them.
+type completionRecord struct {
+	JobID      string    `json:"job_id"`
+	Tenant     string    `json:"tenant"`
+	Type       string    `json:"type"`
+	Result     string    `json:"result"`               // done, failed or expired
+	ErrorKind  string    `json:"error_kind,omitempty"` // errs.Kind of a failed job
+	QueueMs    float64   `json:"queue_ms"`
+	DurationMs float64   `json:"duration_ms"`
+	CPUMs      float64   `json:"cpu_ms"`
+	Region     string    `json:"region,omitempty"`
+	Worker     string    `json:"worker"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// completionExporter publishes a completionRecord per job to a NATS subject
+// that a warehouse loader (or a NATS-to-Kafka/S3 connector) consumes. It is
+// best-effort: publishes are buffered by the NATS client, never block job
+// processing, and a failure only loses the record. A nil exporter is
+// disabled.
+type completionExporter struct {
+	nc          *nats.Conn
+	subject     string
+	region      string
+	serviceName string
+	logger      *zap.Logger
+}
+
+// completions is set by the jobs module when WORKER_EXPORT_SUBJECT is configured.
+var completions *completionExporter
+
+// export publishes the summary of res. queueWait is zero when the publisher
+// didn't stamp the job, and workErr is the handler's error, if any.
+func (e *completionExporter) export(res jobs.Result, queueWait time.Duration, workErr error) {
+	if e == nil {
+		return
+	}
+	rec := completionRecord{
+		JobID:      res.JobID,
+		Tenant:     res.Tenant,
+		Type:       res.Type,
+		Result:     res.Status,
+		QueueMs:    float64(queueWait.Microseconds()) / 1000,
+		DurationMs: res.DurationMs,
+		CPUMs:      res.CPUMs,
+		Region:     e.region,
+		Worker:     res.Worker,
+		FinishedAt: res.FinishedAt,
+	}
+	if workErr != nil {
+		rec.ErrorKind = string(errs.KindOf(workErr))
+	}
+	data, err := json.Marshal(rec)
+	if err == nil {
+		err = e.nc.Publish(e.subject, data)
+	}
+	if err != nil {
+		prom.JobExports.WithLabelValues(e.serviceName, "error").Inc()
+		e.logger.Warn("failed to export job completion",
+			zap.String("job_id", res.JobID),
+			zap.Error(err))
+		return
+	}
+	prom.JobExports.WithLabelValues(e.serviceName, "ok").Inc()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"codigo/internal/jobs"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// runHeartbeat publishes hb, refreshed with the current in-flight count,
+// every interval. Heartbeats go over NATS so workers without database
+// access still show up in the registry.
+func runHeartbeat(nc *nats.Conn, hb jobs.Heartbeat, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		hb.InFlight = int64(runningJobs.count())
+		hb.SentAt = time.Now()
+		data, err := json.Marshal(hb)
+		if err == nil {
+			err = nc.Publish(jobs.HeartbeatSubject, data)
+		}
+		if err != nil {
+			logger.Warn("failed to publish heartbeat", zap.Error(err))
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// runningJob describes a job currently inside processJob.
+type runningJob struct {
+	JobID     string    `json:"job_id"`
+	Tenant    string    `json:"tenant"`
+	Type      string    `json:"type"`
+	TraceID   string    `json:"trace_id"`
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+}
+
+// jobTracker keeps the set of running jobs so operators can see what a pod
+// is doing before killing it.
+type jobTracker struct {
+	mu   sync.Mutex
+	seq  uint64
+	jobs map[uint64]runningJob
+}
+
+var runningJobs = &jobTracker{jobs: make(map[uint64]runningJob)}
+
+// start registers j and returns the function that unregisters it.
+func (t *jobTracker) start(j runningJob) (done func()) {
+	t.mu.Lock()
+	t.seq++
+	id := t.seq
+	t.jobs[id] = j
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		delete(t.jobs, id)
+		t.mu.Unlock()
+	}
+}
+
+func (t *jobTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.jobs)
+}
+
+// snapshot returns running jobs, longest-running first.
+func (t *jobTracker) snapshot() []runningJob {
+	t.mu.Lock()
+	jobs := make([]runningJob, 0, len(t.jobs))
+	for _, j := range t.jobs {
+		j.ElapsedMs = time.Since(j.StartedAt).Milliseconds()
+		jobs = append(jobs, j)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].StartedAt.Before(jobs[k].StartedAt) })
+	return jobs
+}
+
+// ServeHTTP lists running jobs with elapsed time and trace IDs.
+func (t *jobTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"instance": instanceID,
+		"jobs":     t.snapshot(),
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// loadScoreHeader is added to /healthz responses so load balancers and
+// autoscalers that only probe it can still read the load.
+const loadScoreHeader = "X-Load-Score"
+
+// loadTracker rates the worker's load from the queues subscribeQueues
+// registers.
+type loadTracker struct {
+	mu          sync.Mutex
+	dispatchers []*fairDispatcher
+}
+
+var workerLoad = &loadTracker{}
+
+func (t *loadTracker) register(d *fairDispatcher) {
+	t.mu.Lock()
+	t.dispatchers = append(t.dispatchers, d)
+	t.mu.Unlock()
+}
+
+// score rates how busy the worker is from 0 (idle) to 100 (saturated),
+// taking its busiest queue. A queue is saturated once every job slot the
+// limiter allows is running and a tenant's buffer is full behind them, so
+// a worker slowed by database backpressure reads hotter with the same jobs.
+func (t *loadTracker) score() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	load := 0.0
+	for _, d := range t.dispatchers {
+		load = math.Max(load, d.load())
+	}
+	return int(math.Round(100 * math.Min(load, 1)))
+}
+
+// loadWeight reports the load score and the matching traffic weight
+// (100 - load) for weighted load balancing.
+func (t *loadTracker) loadWeight(w http.ResponseWriter, r *http.Request) {
+	load := t.score()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(loadScoreHeader, strconv.Itoa(load))
+	json.NewEncoder(w).Encode(map[string]int{"load": load, "weight": 100 - load})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"codigo/internal/metrics"
+)
+
+func TestLoadScore(t *testing.T) {
+	if prom == nil {
+		prom = metrics.NewWorker(prometheus.NewRegistry(), nil)
+	}
+	tests := []struct {
+		name     string
+		buffered int
+		running  int
+		cut      bool // backpressure cut the limit from 4 to 3
+		want     int
+	}{
+		{name: "idle", want: 0},
+		{name: "running", running: 2, want: 25},
+		{name: "every slot running", running: 4, want: 50},
+		{name: "buffer full behind them", buffered: 4, running: 4, want: 100},
+		{name: "past capacity", buffered: 9, running: 4, want: 100},
+		{name: "limit cut", running: 3, cut: true, want: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newConcurrencyLimiter("codigo-worker", "default", 4)
+			if tt.cut {
+				limiter.decrease()
+			}
+			// The pool's goroutines hold every slot while they wait.
+			for range limiter.current() {
+				limiter.acquire()
+			}
+			d := newFairDispatcher("codigo-worker", "default", 4, limiter)
+			for range tt.buffered + tt.running {
+				d.enqueue("acme", &nats.Msg{Subject: "jobs.acme.report"})
+			}
+			for range tt.running {
+				d.next()
+			}
+			load := &loadTracker{}
+			load.register(d)
+			if got := load.score(); got != tt.want {
+				t.Errorf("score() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"codigo/internal/config"
+	"codigo/internal/contract"
+	"codigo/internal/jobs"
+	"codigo/internal/metrics"
+	"codigo/internal/obs"
+	"codigo/internal/queue"
+)
+
+// prom holds every metric the worker exports. The telemetry module creates
+// it on the binary's registry before anything records.
+var prom *metrics.Worker
+
+// payloadKeys and metricDims are loaded by the jobs module.
+var (
+	payloadKeys *queue.Keyring
+	metricDims  *obs.Dimensions
+)
+
+func main() {
+	fx.New(
+		fx.WithLogger(obs.FxLogger),
+		fx.StopTimeout(config.Duration("SHUTDOWN_TIMEOUT", 45*time.Second)),
+		configModule,
+		loggingModule,
+		telemetryModule,
+		storageModule,
+		queueModule,
+		jobsModule,
+		httpModule,
+	).Run()
+}
+
+func processJob(m *nats.Msg, handler jobHandler, recorder resultRecorder, serviceName string, logger *zap.Logger) {
+	start := time.Now()
+	job, err := contract.Decode(m, payloadKeys)
+	if err != nil {
+		logger.Error("failed to decrypt job payload",
+			zap.String("subject", m.Subject),
+			zap.String("key_id", m.Header.Get(queue.KeyIDHeader)),
+			zap.Error(err))
+		failUnstarted(context.Background(), job, "job payload could not be decrypted", recorder, serviceName, logger)
+		return
+	}
+	jobID, tenant, jobType := job.ID, job.Tenant, job.Type
+	dimTenant, dimType := metricDims.Labels(tenant, jobType)
+
+	// Extract trace context from NATS headers
+	propagator := otel.GetTextMapPropagator()
+	ctx := propagator.Extract(context.Background(), queue.HeaderCarrier(m.Header))
+
+	// Cheap high-volume types may only record a fraction of executions
+	parentCtx := ctx
+	sampled := jobTelemetry.sampled(ctx, jobType)
+	tr := otel.Tracer("codigo-worker")
+	if !sampled {
+		tr = unsampledTracer
+		logger = logger.WithOptions(zap.IncreaseLevel(zapcore.WarnLevel))
+	}
+
+	// Start span with extracted context
+	ctx, span := tr.Start(ctx, "processJob")
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+	spanID := span.SpanContext().SpanID().String()
+
+	done := runningJobs.start(runningJob{
+		JobID:     jobID,
+		Tenant:    tenant,
+		Type:      jobType,
+		TraceID:   traceID,
+		StartedAt: start,
+	})
+	defer done()
+	inFlight := prom.JobsInFlight.WithLabelValues(serviceName, dimType)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	span.SetAttributes(
+		attribute.String("job.id", jobID),
+		attribute.String("job.tenant", tenant),
+		attribute.String("job.type", jobType),
+		attribute.String("nats.subject", m.Subject),
+	)
+	if job.Region != "" {
+		span.SetAttributes(attribute.String("job.origin_region", job.Region))
+	}
+
+	wait, waitOK := queueWait(job, start)
+	if waitOK {
+		prom.JobQueueWait.WithLabelValues(serviceName, jobPriority(job), dimType).Observe(wait.Seconds())
+		span.SetAttributes(attribute.Float64("job.queue_wait_seconds", wait.Seconds()))
+	}
+
+	// Nobody is waiting for a job past its deadline; record it as expired
+	// instead of spending a worker on it.
+	if deadline := job.Deadline; !deadline.IsZero() && !start.Before(deadline) {
+		prom.JobsExpired.WithLabelValues(serviceName, dimTenant, dimType).Inc()
+		span.SetAttributes(attribute.String("job.status", "expired"))
+		logger.Warn("job deadline passed before start",
+			zap.String("trace_id", traceID),
+			zap.String("job_id", jobID),
+			zap.Time("deadline", deadline))
+		res := jobs.Result{
+			JobID:      jobID,
+			Tenant:     tenant,
+			Type:       jobType,
+			Status:     "expired",
+			Error:      "deadline passed before start",
+			Worker:     instanceID,
+			TraceID:    traceID,
+			StartedAt:  start,
+			FinishedAt: time.Now(),
+		}
+		err = recorder.Record(ctx, res)
+		completions.export(res, wait, nil)
+		if err != nil {
+			logger.Error("failed to record job result",
+				zap.String("trace_id", traceID),
+				zap.String("job_id", jobID),
+				zap.Error(err))
+			span.RecordError(err)
+		}
+		return
+	}
+
+	logger.Info("processing job",
+		zap.String("trace_id", traceID),
+		zap.String("span_id", spanID),
+		zap.String("job_id", jobID),
+		zap.String("tenant", tenant),
+		zap.String("type", jobType))
+
+	prom.NATSMessagesReceived.WithLabelValues(serviceName, queue.SubjectLabel(m.Subject)).Inc()
+
+	// What the handler logs through jobs.Logger is also kept with the
+	// attempt, whether or not this execution is sampled.
+	jobLogs := jobs.NewLogBuffer(maxJobLogBytes)
+	jobLogger := jobs.CaptureLogs(logger, jobLogs).With(
+		zap.String("trace_id", traceID),
+		zap.String("job_id", jobID))
+	ctx = jobs.WithLogger(ctx, jobLogger)
+	ctx = jobs.WithPayload(ctx, job.Payload)
+
+	// Pin the goroutine to its thread so thread CPU time is this job's alone
+	runtime.LockOSThread()
+	cpuStart := threadCPUTime()
+
+	workErr := handler(ctx, jobID)
+
+	cpuTime := threadCPUTime() - cpuStart
+	runtime.UnlockOSThread()
+
+	status, errMsg := "done", ""
+	if workErr != nil {
+		status, errMsg = "failed", truncateError(workErr.Error(), maxErrorBytes)
+		logger.Error("job failed",
+			zap.String("trace_id", traceID),
+			zap.String("job_id", jobID),
+			zap.Error(workErr))
+	}
+
+	// Record job result
+	res := jobs.Result{
+		JobID:      jobID,
+		Tenant:     tenant,
+		Type:       jobType,
+		Status:     status,
+		Error:      errMsg,
+		DurationMs: float64(time.Since(start).Milliseconds()),
+		CPUMs:      float64(cpuTime.Microseconds()) / 1000,
+		Worker:     instanceID,
+		TraceID:    traceID,
+		StartedAt:  start,
+		FinishedAt: time.Now(),
+		Logs:       jobLogs.String(),
+	}
+	err = recorder.Record(ctx, res)
+	completions.export(res, wait, workErr)
+	if err != nil {
+		logger.Error("failed to record job result",
+			zap.String("trace_id", traceID),
+			zap.String("job_id", jobID),
+			zap.Error(err))
+	}
+	if err = errors.Join(workErr, err); err != nil {
+		span.RecordError(err)
+		if !sampled {
+			// Failures are always traced, even for unsampled types
+			_, errSpan := otel.Tracer("codigo-worker").Start(parentCtx, "processJob", trace.WithTimestamp(start))
+			errSpan.SetAttributes(
+				attribute.String("job.id", jobID),
+				attribute.String("job.tenant", tenant),
+				attribute.String("job.type", jobType),
+			)
+			errSpan.RecordError(err)
+			errSpan.End()
+		}
+		prom.JobsProcessed.WithLabelValues(serviceName, "error", dimTenant, dimType).Inc()
+		return
+	}
+
+	duration := time.Since(start)
+	prom.JobsProcessed.WithLabelValues(serviceName, "ok", dimTenant, dimType).Inc()
+	prom.JobLatency.WithLabelValues(serviceName).Observe(duration.Seconds())
+
+	span.SetAttributes(
+		attribute.String("job.status", "done"),
+		attribute.Float64("job.duration_ms", float64(duration.Milliseconds())),
+	)
+
+	logger.Info("job processed successfully",
+		zap.String("trace_id", traceID),
+		zap.String("job_id", jobID),
+		zap.Duration("duration", duration))
+}
+
+func updateDBMetrics(db *pgxpool.Pool, serviceName string) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	var emptyAcquires int64
+	for range ticker.C {
+		stats := db.Stat()
+		prom.DBConnections.WithLabelValues(serviceName, "default").Set(float64(stats.AcquiredConns()))
+		prom.DBMaxConnections.WithLabelValues(serviceName, "default").Set(float64(stats.MaxConns()))
+		prom.DBEmptyAcquires.WithLabelValues(serviceName, "default").Add(float64(stats.EmptyAcquireCount() - emptyAcquires))
+		emptyAcquires = stats.EmptyAcquireCount()
+	}
+}
+
+// instanceID identifies this worker pod in job attempt records.
+var instanceID = func() string {
+	if h, err := os.Hostname(); err == nil {
+		return h
+	}
+	return "unknown"
+}()
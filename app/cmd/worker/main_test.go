@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"codigo/internal/contract"
+	"codigo/internal/fakes"
+	"codigo/internal/jobs"
+	"codigo/internal/metrics"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+// BenchmarkProcessJob measures a worker running a job that does nothing,
+// from decoding the message to recording the result with each recorder
+// WORKER_RESULT_MODE can pick, on an in-memory Postgres and NATS.
+func BenchmarkProcessJob(b *testing.B) {
+	if prom == nil {
+		prom = metrics.NewWorker(prometheus.NewRegistry(), nil)
+	}
+	var err error
+	if payloadKeys, err = queue.LoadKeyring(); err != nil {
+		b.Fatal(err)
+	}
+	logger := zap.NewNop()
+	m, err := contract.Encode(contract.Job{
+		ID:          "job_1",
+		Tenant:      "acme",
+		Type:        "report",
+		PublishedAt: time.Now(),
+		Payload:     []byte(`{"ref":"refs/heads/main","size":3}`),
+	}, payloadKeys)
+	if err != nil {
+		b.Fatal(err)
+	}
+	handler := func(context.Context, string) error { return nil }
+
+	b.Run("db", func(b *testing.B) {
+		db, err := fakes.PostgresPool(context.Background(), func(cfg *pgxpool.Config) {
+			cfg.ConnConfig.Tracer = &storage.SlowQueryTracer{Logger: logger, Threshold: storage.QueryTimeout}
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.Close()
+		recorder := &dbRecorder{db: db, serviceName: "codigo-worker", logger: logger}
+		// processJob only logs a failed record, which would go unnoticed.
+		if err := recorder.Record(context.Background(), jobs.Result{JobID: "job_1", Status: "done"}); err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for range b.N {
+			processJob(m, handler, recorder, "codigo-worker", logger)
+		}
+	})
+
+	b.Run("nats", func(b *testing.B) {
+		nc, err := fakes.NewNATS().Connect()
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer nc.Close()
+		recorder := &natsRecorder{nc: nc, subject: "jobs.results"}
+		// processJob only logs a failed record, which would go unnoticed.
+		if err := recorder.Record(context.Background(), jobs.Result{JobID: "job_1", Status: "done"}); err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for range b.N {
+			processJob(m, handler, recorder, "codigo-worker", logger)
+		}
+	})
+}
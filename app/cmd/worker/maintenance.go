@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"codigo/internal/jobs"
+)
+
+// maintenanceGate pauses job consumption during maintenance windows the
+// API announces on jobs.MaintenanceSubject, or MAINTENANCE_MODE sets at
+// startup. The queue consumers stop subscribing, so no more jobs are taken
+// on; running jobs finish and received jobs stay buffered in the
+// dispatcher until the window ends.
+type maintenanceGate struct {
+	serviceName string
+	logger      *zap.Logger
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	state     jobs.Maintenance
+	consumers []*queueConsumer
+}
+
+func newMaintenanceGate(serviceName string, logger *zap.Logger) *maintenanceGate {
+	g := &maintenanceGate{serviceName: serviceName, logger: logger}
+	g.cond = sync.NewCond(&g.mu)
+	if os.Getenv("MAINTENANCE_MODE") == "true" {
+		g.state = jobs.Maintenance{Enabled: true, Reason: os.Getenv("MAINTENANCE_REASON"), SetAt: time.Now()}
+		prom.MaintenanceMode.WithLabelValues(serviceName).Set(1)
+	} else {
+		prom.MaintenanceMode.WithLabelValues(serviceName).Set(0)
+	}
+	return g
+}
+
+// attach puts c under the gate, subscribing it unless maintenance is on.
+func (g *maintenanceGate) attach(c *queueConsumer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.consumers = append(g.consumers, c)
+	if g.state.Enabled {
+		return nil
+	}
+	return c.subscribe()
+}
+
+// enabled reports whether maintenance is on.
+func (g *maintenanceGate) enabled() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.state.Enabled
+}
+
+// wait blocks while maintenance is on.
+func (g *maintenanceGate) wait() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for g.state.Enabled {
+		g.cond.Wait()
+	}
+}
+
+// observe applies an announcement unless a newer state is already in place.
+func (g *maintenanceGate) observe(m *nats.Msg) {
+	var state jobs.Maintenance
+	if err := json.Unmarshal(m.Data, &state); err != nil {
+		g.logger.Warn("invalid maintenance announcement", zap.Error(err))
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if state.SetAt.Before(g.state.SetAt) {
+		return
+	}
+	changed := state.Enabled != g.state.Enabled
+	g.state = state
+	if !changed {
+		return
+	}
+	if state.Enabled {
+		g.logger.Warn("maintenance mode on, pausing job consumption", zap.String("reason", state.Reason))
+		prom.MaintenanceMode.WithLabelValues(g.serviceName).Set(1)
+		for _, c := range g.consumers {
+			c.pause()
+		}
+	} else {
+		g.logger.Info("maintenance mode off, resuming job consumption")
+		prom.MaintenanceMode.WithLabelValues(g.serviceName).Set(0)
+		for _, c := range g.consumers {
+			if err := c.subscribe(); err != nil {
+				g.logger.Error("failed to resume job consumption", zap.String("queue", c.queue.Name), zap.Error(err))
+			}
+		}
+	}
+	g.cond.Broadcast()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/jobs"
+	"codigo/internal/metrics"
+	"codigo/internal/obs"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+// The worker is assembled from these fx modules. A new component gets its
+// own module, or a constructor in the module whose dependencies it shares,
+// and is listed in main; nothing else needs to know about it.
+var (
+	configModule = fx.Module("config",
+		fx.Provide(loadAppConfig),
+	)
+	loggingModule = fx.Module("logging",
+		fx.Provide(obs.NewLogger),
+	)
+	telemetryModule = fx.Module("telemetry",
+		fx.Provide(newMetricsRegistry, newTracing),
+	)
+	storageModule = fx.Module("storage",
+		fx.Provide(newVault, newRecorder),
+	)
+	queueModule = fx.Module("queue",
+		fx.Provide(newNATSConn, newCompletionStream),
+	)
+	jobsModule = fx.Module("jobs",
+		fx.Invoke(loadJobSettings, startExporter, startWatchdog, subscribeQueues),
+	)
+	httpModule = fx.Module("http",
+		fx.Invoke(serveHTTP),
+	)
+)
+
+// loadAppConfig is the configuration every module shares.
+func loadAppConfig() config.App {
+	return config.LoadApp("codigo-worker")
+}
+
+// newMetricsRegistry creates the worker metrics on the registry /metrics
+// serves.
+func newMetricsRegistry(logger *zap.Logger) (*prometheus.Registry, error) {
+	cfg, err := metrics.ParseConfig(config.String("METRICS_PREFIX", ""), config.String("METRICS_CONST_LABELS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics configuration: %w", err)
+	}
+	registry, registerer := metrics.NewRegistry(cfg)
+	prom = metrics.NewWorker(registerer, queueWaitBuckets(config.String("JOB_QUEUE_WAIT_BUCKETS", ""), logger))
+	return registry, nil
+}
+
+// tracing is provided once the global OpenTelemetry tracer provider and
+// propagator are set. Components that start spans from then on depend on
+// it.
+type tracing struct{}
+
+func newTracing(lc fx.Lifecycle, cfg config.App) tracing {
+	shutdown := obs.InitTracing(context.Background(), cfg.ServiceName, cfg.Region, &prom.Common)
+	lc.Append(fx.StopHook(shutdown))
+	return tracing{}
+}
+
+// newVault is the optional Vault client for Postgres and NATS credentials;
+// nil without VAULT_ADDR.
+func newVault() (*config.Vault, error) {
+	vault, err := config.NewVault(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize vault client: %w", err)
+	}
+	return vault, nil
+}
+
+// newRecorder returns where job results go. In "nats" mode the worker
+// publishes completion events for the API to record, and announce on
+// jobs.completed, and never connects to Postgres.
+func newRecorder(lc fx.Lifecycle, cfg config.App, logger *zap.Logger, vault *config.Vault, nc *nats.Conn, completed *jobs.CompletionStream) (resultRecorder, error) {
+	switch mode := config.String("WORKER_RESULT_MODE", "db"); mode {
+	case "db":
+		pools, err := storage.Open(context.Background(), logger, vault)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database pool: %w", err)
+		}
+		db := pools[0]
+		lc.Append(fx.StopHook(db.Close))
+
+		// Back off job consumption while the pool is the bottleneck
+		if threshold := config.Duration("WORKER_DB_WAIT_THRESHOLD", 50*time.Millisecond); threshold > 0 {
+			backpressure = &dbBackpressure{db: db, threshold: threshold, logger: logger}
+		}
+		lc.Append(fx.StartHook(func() {
+			go updateDBMetrics(db, cfg.ServiceName)
+			if backpressure != nil {
+				go backpressure.run(config.Duration("WORKER_BACKPRESSURE_INTERVAL", 5*time.Second))
+			}
+		}))
+		return &dbRecorder{db: db, completed: completed, serviceName: cfg.ServiceName, logger: logger}, nil
+	case "nats":
+		return &natsRecorder{nc: nc, subject: config.String("RESULTS_SUBJECT", "jobs.results"), region: cfg.Region}, nil
+	default:
+		return nil, fmt.Errorf("invalid WORKER_RESULT_MODE %q", mode)
+	}
+}
+
+func newNATSConn(lc fx.Lifecycle, logger *zap.Logger, vault *config.Vault) (*nats.Conn, error) {
+	nc, err := queue.Connect(logger, vault)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	lc.Append(fx.StopHook(nc.Close))
+	return nc, nil
+}
+
+// newCompletionStream is the jobs.completed publisher for results this
+// binary records; nil without JOBS_COMPLETED_STREAM.
+func newCompletionStream(nc *nats.Conn) (*jobs.CompletionStream, error) {
+	stream := config.String("JOBS_COMPLETED_STREAM", "")
+	if stream == "" {
+		return nil, nil
+	}
+	cs, err := jobs.NewCompletionStream(nc, stream, config.Duration("JOBS_COMPLETED_MAX_AGE", 72*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the job completion stream: %w", err)
+	}
+	return cs, nil
+}
+
+// loadJobSettings loads the settings processJob reads: payload keys, size
+// limits, metric dimensions and telemetry sampling. It takes the registry so the metrics
+// whose series the dimensions delete exist by then.
+func loadJobSettings(logger *zap.Logger, _ *prometheus.Registry) error {
+	if maxErrorBytes <= 0 {
+		return fmt.Errorf("WORKER_MAX_ERROR_BYTES must be positive, got %d", maxErrorBytes)
+	}
+	if maxJobLogBytes <= 0 {
+		return fmt.Errorf("WORKER_JOB_LOG_BYTES must be positive, got %d", maxJobLogBytes)
+	}
+	var err error
+	payloadKeys, err = queue.LoadKeyring()
+	if err != nil {
+		return fmt.Errorf("invalid payload encryption keys: %w", err)
+	}
+	metricDims = obs.LoadDimensions()
+	metricDims.DeleteEvicted(
+		prom.JobsProcessed.MetricVec,
+		prom.JobsExpired.MetricVec,
+		prom.JobsInFlight.MetricVec,
+		prom.JobQueueWait.MetricVec,
+		prom.TenantJobsDispatched.MetricVec,
+		prom.TenantJobsRefused.MetricVec,
+		prom.TenantQueueDepth.MetricVec,
+	)
+	jobTelemetry = parseTelemetrySampling(os.Getenv("JOB_TELEMETRY_SAMPLE"), logger)
+	return nil
+}
+
+// startExporter sets up the optional per-job completion records for offline
+// analytics.
+func startExporter(cfg config.App, logger *zap.Logger, nc *nats.Conn) {
+	if subject := os.Getenv("WORKER_EXPORT_SUBJECT"); subject != "" {
+		completions = &completionExporter{nc: nc, subject: subject, region: cfg.Region, serviceName: cfg.ServiceName, logger: logger}
+	}
+}
+
+// startWatchdog runs the leak watchdog for long-running pods.
+func startWatchdog(lc fx.Lifecycle, cfg config.App, logger *zap.Logger) {
+	interval := config.Duration("WORKER_WATCHDOG_INTERVAL", time.Minute)
+	if interval <= 0 {
+		return
+	}
+	wd := &watchdog{
+		serviceName:   cfg.ServiceName,
+		maxGoroutines: config.Int("WORKER_WATCHDOG_MAX_GOROUTINES", 10000),
+		heapGrowth:    config.Int("WORKER_WATCHDOG_HEAP_GROWTH", 4),
+		stuckAfter:    config.Duration("WORKER_WATCHDOG_STUCK_AFTER", 10*time.Minute),
+		logger:        logger,
+	}
+	lc.Append(fx.StartHook(func() { go wd.run(interval) }))
+}
+
+// subscribeQueues starts consuming jobs once the app starts. Each queue is
+// a set of subjects with its own handler and worker pool. Within a queue,
+// jobs are buffered per tenant and served round-robin by a fixed pool of
+// goroutines so one tenant's backlog can't starve the others. Replicas
+// share each queue's group so a job is processed once. While Postgres is
+// the bottleneck, backpressure idles part of each pool and admits fewer
+// jobs per tenant, refusing the rest back to the API. Maintenance windows
+// unsubscribe every queue and idle the whole pool. On stop the worker drains before the
+// database pool and the connection, which were set up first, are closed.
+func subscribeQueues(lc fx.Lifecycle, _ tracing, cfg config.App, logger *zap.Logger, nc *nats.Conn, recorder resultRecorder) error {
+	queues, err := loadQueues()
+	if err != nil {
+		return fmt.Errorf("invalid worker queue configuration: %w", err)
+	}
+	var (
+		consumers   []*queueConsumer
+		dispatchers []*fairDispatcher
+		maintenance *maintenanceGate
+	)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			// Subscribe before consuming so a window announced at startup
+			// holds the first jobs too
+			maintenance = newMaintenanceGate(cfg.ServiceName, logger)
+			if _, err := nc.Subscribe(jobs.MaintenanceSubject, maintenance.observe); err != nil {
+				return fmt.Errorf("failed to subscribe to maintenance announcements: %w", err)
+			}
+
+			var subjects []string
+			concurrency := 0
+			for _, q := range queues {
+				limiter := newConcurrencyLimiter(cfg.ServiceName, q.Name, q.Concurrency)
+				backpressure.register(limiter)
+				dispatcher := newFairDispatcher(cfg.ServiceName, q.Name, q.TenantQueueLimit, limiter)
+				dispatchers = append(dispatchers, dispatcher)
+				workerLoad.register(dispatcher)
+				handler := jobHandlers[q.Handler]
+				for i := 0; i < q.Concurrency; i++ {
+					go func() {
+						for {
+							maintenance.wait()
+							limiter.acquire()
+							processJob(dispatcher.next(), handler, recorder, cfg.ServiceName, logger)
+							dispatcher.finish()
+							limiter.release()
+						}
+					}()
+				}
+				consumer := newQueueConsumer(nc, q, func(m *nats.Msg) {
+					tenant, _ := queue.TenantType(m.Subject)
+					reply := queue.DispatchAccepted
+					if !dispatcher.enqueue(tenant, m) {
+						reply = queue.DispatchBusy
+						logger.Warn("tenant buffer full, refusing job",
+							zap.String("queue", q.Name),
+							zap.String("subject", m.Subject))
+					}
+					if m.Reply != "" {
+						if err := m.Respond([]byte(reply)); err != nil {
+							logger.Warn("failed to answer job dispatch", zap.String("subject", m.Subject), zap.Error(err))
+						}
+					}
+				}, logger)
+				if err := maintenance.attach(consumer); err != nil {
+					return err
+				}
+				consumers = append(consumers, consumer)
+				subjects = append(subjects, q.Subjects...)
+				concurrency += q.Concurrency
+				logger.Info("queue subscribed",
+					zap.String("queue", q.Name),
+					zap.Strings("subjects", q.Subjects),
+					zap.String("queue_group", q.QueueGroup),
+					zap.Int("concurrency", q.Concurrency),
+					zap.String("handler", q.Handler))
+			}
+
+			go runHeartbeat(nc, jobs.Heartbeat{
+				Instance:    instanceID,
+				Version:     version,
+				Region:      cfg.Region,
+				StartedAt:   time.Now(),
+				Subjects:    subjects,
+				Concurrency: concurrency,
+			}, config.Duration("WORKER_HEARTBEAT_INTERVAL", 10*time.Second), logger)
+
+			logger.Info("worker running",
+				zap.Int("queues", len(queues)),
+				zap.Strings("subjects", subjects),
+				zap.Int("concurrency", concurrency))
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			drain(ctx, consumers, dispatchers, maintenance, recorder, cfg.ServiceName, logger)
+			return nil
+		},
+	})
+	return nil
+}
+
+// serveHTTP starts the metrics and probe HTTP servers. With mTLS
+// configured, /metrics is served on a separate listener and only /healthz
+// stays on the plain port for probes. METRICS_ADDR moves /metrics to a
+// separate plain listener instead. /healthz carries the load score, which
+// /healthz/weight reports with a traffic weight. /debug/jobs lists tenants and trace IDs,
+// so it needs an X-Admin-Key from ADMIN_API_KEYS like the API's admin
+// routes.
+func serveHTTP(lc fx.Lifecycle, logger *zap.Logger, registry *prometheus.Registry) error {
+	if err := obs.ServeMetrics(lc, logger, registry, http.Handle); err != nil {
+		return err
+	}
+	http.Handle("/debug/jobs", loadAdminKeys().require(runningJobs))
+	http.Handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(loadScoreHeader, strconv.Itoa(workerLoad.score()))
+		w.WriteHeader(200)
+		w.Write([]byte("ok"))
+	}))
+	http.HandleFunc("/healthz/weight", workerLoad.loadWeight)
+	httpListener, err := config.Listen("http", config.String("HTTP_ADDR", ":8080"))
+	if err != nil {
+		return fmt.Errorf("http listener failed: %w", err)
+	}
+	lc.Append(fx.StartHook(func() {
+		go func() {
+			logger.Info("http server starting", zap.String("address", httpListener.Addr().String()))
+			if err := http.Serve(httpListener, nil); err != nil {
+				logger.Fatal("http server failed", zap.Error(err))
+			}
+		}()
+	}))
+	return nil
+}
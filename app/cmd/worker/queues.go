@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"codigo/internal/config"
+	"codigo/internal/jobs"
+	"codigo/internal/queue"
+)
+
+// jobHandler does the work of one job. A returned error marks the job
+// failed. Handlers log through jobs.Logger(ctx) so the lines are kept with
+// the attempt.
+type jobHandler func(ctx context.Context, jobID string) error
+
+// jobHandlers are the handlers a queue can name in its configuration.
+var jobHandlers = map[string]jobHandler{
+	"simulate": simulateWork,
+}
+
+// defaultHandler runs jobs of queues that don't name a handler.
+const defaultHandler = "simulate"
+
+// simulateWork stands in for real job processing.
+func simulateWork(ctx context.Context, jobID string) error {
+	jobs.Logger(ctx).Info("simulating work")
+	time.Sleep(150 * time.Millisecond)
+	return nil
+}
+
+var queueNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// queueConfig is one logical queue the worker consumes: a set of subjects
+// served by their own goroutine pool and handler.
+type queueConfig struct {
+	Name             string   `json:"name"`
+	Subjects         []string `json:"subjects"`
+	QueueGroup       string   `json:"queue_group"`
+	Concurrency      int      `json:"concurrency"`
+	TenantQueueLimit int      `json:"tenant_queue_limit"`
+	Handler          string   `json:"handler"`
+}
+
+// loadQueues reads the queues from the JSON array in WORKER_QUEUES_FILE.
+// Without it the worker runs a single "default" queue configured by
+// WORKER_SUBJECTS, WORKER_QUEUE_GROUP, WORKER_CONCURRENCY and
+// WORKER_TENANT_QUEUE_LIMIT.
+func loadQueues() ([]queueConfig, error) {
+	path := os.Getenv("WORKER_QUEUES_FILE")
+	if path == "" {
+		q := queueConfig{
+			Name:             "default",
+			QueueGroup:       config.String("WORKER_QUEUE_GROUP", "codigo-worker"),
+			Concurrency:      config.Int("WORKER_CONCURRENCY", 1),
+			TenantQueueLimit: config.Int("WORKER_TENANT_QUEUE_LIMIT", 1000),
+			Handler:          defaultHandler,
+		}
+		for _, subject := range strings.Split(config.String("WORKER_SUBJECTS", "jobs.*.*"), ",") {
+			q.Subjects = append(q.Subjects, strings.TrimSpace(subject))
+		}
+		return []queueConfig{q}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var queues []queueConfig
+	if err := json.Unmarshal(b, &queues); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("%s declares no queues", path)
+	}
+
+	names := make(map[string]bool)
+	for i := range queues {
+		q := &queues[i]
+		if !queueNamePattern.MatchString(q.Name) || names[q.Name] {
+			return nil, fmt.Errorf("queue %d: name %q must be unique and 1-64 characters of [A-Za-z0-9_-]", i, q.Name)
+		}
+		names[q.Name] = true
+		if len(q.Subjects) == 0 {
+			return nil, fmt.Errorf("queue %s has no subjects", q.Name)
+		}
+		// Queues subscribe in their own groups, so a job matching two of
+		// them would run once in each
+		for _, other := range queues[:i] {
+			for _, s := range q.Subjects {
+				for _, o := range other.Subjects {
+					if queue.SubjectsOverlap(s, o) {
+						return nil, fmt.Errorf("subject %s of queue %s overlaps subject %s of queue %s", s, q.Name, o, other.Name)
+					}
+				}
+			}
+		}
+		if q.QueueGroup == "" {
+			q.QueueGroup = "codigo-worker-" + q.Name
+		}
+		if q.Concurrency <= 0 {
+			q.Concurrency = 1
+		}
+		if q.TenantQueueLimit <= 0 {
+			q.TenantQueueLimit = 1000
+		}
+		if q.Handler == "" {
+			q.Handler = defaultHandler
+		}
+		if _, ok := jobHandlers[q.Handler]; !ok {
+			return nil, fmt.Errorf("queue %s: unknown handler %q", q.Name, q.Handler)
+		}
+	}
+	return queues, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadQueuesRejectsOverlappingSubjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		queues  string
+		overlap bool
+	}{
+		{"disjoint types", `[{"name": "bulk", "subjects": ["jobs.*.export"]}, {"name": "rest", "subjects": ["jobs.*.thumbnail"]}]`, false},
+		{"disjoint tenants", `[{"name": "acme", "subjects": ["jobs.acme.*"]}, {"name": "globex", "subjects": ["jobs.globex.*"]}]`, false},
+		{"identical", `[{"name": "a", "subjects": ["jobs.*.*"]}, {"name": "b", "subjects": ["jobs.*.*"]}]`, true},
+		{"tenant inside catch-all", `[{"name": "shared", "subjects": ["jobs.*.*"]}, {"name": "acme", "subjects": ["jobs.acme.*"]}]`, true},
+		{"crossing wildcards", `[{"name": "acme", "subjects": ["jobs.acme.*"]}, {"name": "export", "subjects": ["jobs.*.export"]}]`, true},
+		{"full wildcard", `[{"name": "all", "subjects": ["jobs.>"]}, {"name": "one", "subjects": ["jobs.acme.export"]}]`, true},
+		{"different depth", `[{"name": "a", "subjects": ["jobs.*"]}, {"name": "b", "subjects": ["jobs.*.*"]}]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "queues.json")
+			if err := os.WriteFile(path, []byte(tt.queues), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("WORKER_QUEUES_FILE", path)
+
+			_, err := loadQueues()
+			if tt.overlap && (err == nil || !strings.Contains(err.Error(), "overlaps")) {
+				t.Fatalf("loadQueues() error = %v, want overlap error", err)
+			}
+			if !tt.overlap && err != nil {
+				t.Fatalf("loadQueues() error = %v, want nil", err)
+			}
+		})
+	}
+}
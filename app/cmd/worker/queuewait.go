@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"codigo/internal/contract"
+)
+
+// queueWaitBuckets parses spec as comma-separated bucket bounds in seconds
+// for codigo_job_queue_wait_seconds. It returns nil, meaning the defaults, when
+// spec is empty or invalid.
+func queueWaitBuckets(spec string, logger *zap.Logger) []float64 {
+	if spec == "" {
+		return nil
+	}
+	var parsed []float64
+	for _, f := range strings.Split(spec, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		// Negated so NaN, which compares false, is rejected too.
+		if err != nil || !(v > 0) || (len(parsed) > 0 && !(v > parsed[len(parsed)-1])) {
+			logger.Warn("invalid JOB_QUEUE_WAIT_BUCKETS, using defaults", zap.String("value", spec))
+			return nil
+		}
+		parsed = append(parsed, v)
+	}
+	return parsed
+}
+
+// queueWait returns how long job waited between publish and start. Jobs
+// from publishers that don't stamp the publish time are skipped.
+func queueWait(job contract.Job, start time.Time) (time.Duration, bool) {
+	if job.PublishedAt.IsZero() {
+		return 0, false
+	}
+	wait := start.Sub(job.PublishedAt)
+	if wait < 0 {
+		// Clock skew between API and worker hosts
+		wait = 0
+	}
+	return wait, true
+}
+
+// jobPriority is the priority label for job.
+func jobPriority(job contract.Job) string {
+	if job.Priority == "" {
+		return "normal"
+	}
+	return job.Priority
+}
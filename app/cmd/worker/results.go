@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/jobs"
+	"codigo/internal/queue"
+	"codigo/internal/storage"
+)
+
+// maxErrorBytes caps the error text stored per attempt and sent in result
+// events, so a handler returning a huge error can't bloat job_attempts or
+// exceed the NATS message size.
+var maxErrorBytes = config.Int("WORKER_MAX_ERROR_BYTES", 4096)
+
+// maxJobLogBytes caps the handler log lines kept per attempt, for the same
+// reasons.
+var maxJobLogBytes = config.Int("WORKER_JOB_LOG_BYTES", 64<<10)
+
+// minErrorBytes is the smallest limit truncateError applies: room for the
+// longest truncation marker and some of the error itself.
+const minErrorBytes = 64
+
+// truncateError shortens msg to at most limit bytes, cutting at a rune
+// boundary and ending with a marker that says how much was dropped. Limits
+// below minErrorBytes are raised to it, so the marker always fits.
+func truncateError(msg string, limit int) string {
+	limit = max(limit, minErrorBytes)
+	if len(msg) <= limit {
+		return msg
+	}
+	// The marker for all of msg is at least as long as the final one.
+	marker := fmt.Sprintf("... [truncated %d bytes]", len(msg))
+	cut := limit - len(marker)
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + fmt.Sprintf("... [truncated %d bytes]", len(msg)-cut)
+}
+
+// resultRecorder persists the outcome of a processed job.
+type resultRecorder interface {
+	Record(ctx context.Context, res jobs.Result) error
+}
+
+// dbRecorder writes job results straight to Postgres, then announces them
+// on jobs.completed when a completion stream is configured.
+type dbRecorder struct {
+	db          *pgxpool.Pool
+	completed   *jobs.CompletionStream
+	serviceName string
+	logger      *zap.Logger
+}
+
+func (r *dbRecorder) Record(ctx context.Context, res jobs.Result) error {
+	txDuration := prom.DBTxDuration.MustCurryWith(prometheus.Labels{"service": r.serviceName})
+	var attempt int
+	err := storage.WithTx(ctx, r.db, txDuration, func(tx pgx.Tx) error {
+		qctx, cancel := storage.WithQuery(ctx, "complete_job")
+		defer cancel()
+		var err error
+		attempt, err = jobs.Record(qctx, tx, res)
+		return err
+	})
+	if err != nil || r.completed == nil {
+		return err
+	}
+
+	// The result is stored; a lost event is logged and counted rather than
+	// failing the job, which would have it redelivered and recorded twice.
+	if err := r.completed.Publish(ctx, res, attempt); err != nil {
+		r.logger.Error("failed to publish job completion",
+			zap.String("trace_id", res.TraceID),
+			zap.String("job_id", res.JobID),
+			zap.Error(err))
+		prom.JobCompletedEvents.WithLabelValues(r.serviceName, "error").Inc()
+		return nil
+	}
+	prom.JobCompletedEvents.WithLabelValues(r.serviceName, "ok").Inc()
+	return nil
+}
+
+// natsRecorder publishes job results for the API to record, so the worker
+// needs no database access at all.
+type natsRecorder struct {
+	nc      *nats.Conn
+	subject string
+	region  string
+}
+
+func (r *natsRecorder) Record(ctx context.Context, res jobs.Result) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	headers := make(nats.Header)
+	otel.GetTextMapPropagator().Inject(ctx, queue.HeaderCarrier(headers))
+	if r.region != "" {
+		headers.Set(queue.RegionHeader, r.region)
+	}
+	return r.nc.PublishMsg(&nats.Msg{
+		Subject: r.subject,
+		Data:    data,
+		Header:  headers,
+	})
+}
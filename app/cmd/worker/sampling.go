@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+
+	"codigo/internal/obs"
+)
+
+// telemetrySampling holds per-type fractions of executions that emit spans
+// and info logs. Types without an entry are always recorded. Errors are
+// logged whatever the fraction, and failing jobs get their span emitted
+// after the fact.
+type telemetrySampling map[string]float64
+
+// jobTelemetry is loaded by the jobs module from JOB_TELEMETRY_SAMPLE.
+var jobTelemetry telemetrySampling
+
+// unsampledTracer starts spans that only carry the parent's context, so
+// trace IDs still reach logs and results without anything being exported.
+var unsampledTracer = noop.NewTracerProvider().Tracer("codigo-worker")
+
+// parseTelemetrySampling reads comma-separated type=fraction pairs, e.g.
+// "thumbnail=0.01,resize=0.1".
+func parseTelemetrySampling(spec string, logger *zap.Logger) telemetrySampling {
+	ts := make(telemetrySampling)
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		jobType, v, _ := strings.Cut(entry, "=")
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f >= 0 && f <= 1) { // NaN fails both comparisons
+			logger.Warn("ignoring invalid JOB_TELEMETRY_SAMPLE entry", zap.String("entry", entry))
+			continue
+		}
+		ts[jobType] = f
+	}
+	return ts
+}
+
+// sampled decides whether this execution of jobType records full telemetry.
+// Debug traces forced by an admin are always recorded.
+func (ts telemetrySampling) sampled(ctx context.Context, jobType string) bool {
+	f, ok := ts[jobType]
+	if !ok || f >= 1 {
+		return true
+	}
+	if obs.DebugTraceEnabled(ctx) {
+		return true
+	}
+	return rand.Float64() < f
+}
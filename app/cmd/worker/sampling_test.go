@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func FuzzParseTelemetrySampling(f *testing.F) {
+	for _, seed := range []string{"report=0.1, export=1", "x=NaN", "=0.5,,y", "a=2,b=-0"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		for jobType, rate := range parseTelemetrySampling(spec, zap.NewNop()) {
+			if !(rate >= 0 && rate <= 1) {
+				t.Errorf("parseTelemetrySampling(%q)[%q] = %v, want a rate in [0, 1]", spec, jobType, rate)
+			}
+		}
+	})
+}
+
+func FuzzQueueWaitBuckets(f *testing.F) {
+	for _, seed := range []string{"0.1,1,10", "1,NaN", "5, 1", "1,+Inf", "0x1p-2"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		buckets := queueWaitBuckets(spec, zap.NewNop())
+		if buckets == nil {
+			return
+		}
+		for i, b := range buckets {
+			if !(b > 0) || i > 0 && !(b > buckets[i-1]) {
+				t.Fatalf("queueWaitBuckets(%q) = %v, want positive increasing bounds", spec, buckets)
+			}
+		}
+		// Buckets the worker accepts must not panic the histogram it builds.
+		prometheus.NewHistogram(prometheus.HistogramOpts{Name: "queue_wait_seconds", Buckets: buckets})
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxStackDump bounds the goroutine dump logged with an alert.
+const maxStackDump = 1 << 20
+
+// watchdog periodically checks for the slow leaks of a long-lived worker:
+// a growing goroutine count, a heap far above its starting size and jobs
+// that never finish. Each finding is logged with the goroutine stacks and
+// counted in watchdog_alerts_total by check.
+type watchdog struct {
+	serviceName   string
+	maxGoroutines int
+	heapGrowth    int
+	stuckAfter    time.Duration
+	logger        *zap.Logger
+
+	// Each problem is reported once, until it clears.
+	heapBaseline   uint64
+	overHeap       bool
+	overGoroutines bool
+	stuck          map[string]bool // jobs already reported, by job ID
+}
+
+// run checks every interval until the process exits.
+func (d *watchdog) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.stuck = make(map[string]bool)
+	for range ticker.C {
+		d.check()
+	}
+}
+
+func (d *watchdog) check() {
+	var alerts []zap.Field
+
+	n := runtime.NumGoroutine()
+	if n > d.maxGoroutines && !d.overGoroutines {
+		d.alert("goroutines")
+		alerts = append(alerts, zap.Int("goroutines", n), zap.Int("max_goroutines", d.maxGoroutines))
+	}
+	d.overGoroutines = n > d.maxGoroutines
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if d.heapBaseline == 0 {
+		// Measured after the first interval so startup allocations settle;
+		// small heaps are not worth alerting on.
+		d.heapBaseline = max(mem.HeapAlloc, 16<<20)
+	}
+	overHeap := mem.HeapAlloc > uint64(d.heapGrowth)*d.heapBaseline
+	if overHeap && !d.overHeap {
+		d.alert("heap")
+		alerts = append(alerts, zap.Uint64("heap_alloc_bytes", mem.HeapAlloc), zap.Uint64("heap_baseline_bytes", d.heapBaseline))
+	}
+	d.overHeap = overHeap
+
+	running := make(map[string]bool)
+	for _, j := range runningJobs.snapshot() {
+		running[j.JobID] = true
+		if d.stuckAfter <= 0 || time.Since(j.StartedAt) < d.stuckAfter || d.stuck[j.JobID] {
+			continue
+		}
+		d.stuck[j.JobID] = true
+		d.alert("stuck_job")
+		alerts = append(alerts, zap.String("stuck_job_id", j.JobID), zap.String("stuck_job_trace_id", j.TraceID), zap.Duration("stuck_job_elapsed", time.Since(j.StartedAt)))
+	}
+	for id := range d.stuck {
+		if !running[id] {
+			delete(d.stuck, id)
+		}
+	}
+
+	if len(alerts) == 0 {
+		return
+	}
+	buf := make([]byte, maxStackDump)
+	buf = buf[:runtime.Stack(buf, true)]
+	d.logger.Warn("watchdog alert", append(alerts, zap.ByteString("stacks", buf))...)
+}
+
+func (d *watchdog) alert(check string) {
+	prom.WatchdogAlerts.WithLabelValues(d.serviceName, check).Inc()
+}
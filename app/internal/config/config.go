@@ -0,0 +1,54 @@
+// Package config reads the settings the API and worker share: environment
+// variables, Vault credentials and the listeners to serve on.
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// App is the configuration every component of a binary shares. Components
+// read their own settings from the environment where they are built.
+type App struct {
+	ServiceName string
+	Region      string
+}
+
+// LoadApp reads SERVICE_NAME, defaulting to defaultService, and REGION.
+func LoadApp(defaultService string) App {
+	return App{
+		ServiceName: String("SERVICE_NAME", defaultService),
+		Region:      os.Getenv("REGION"),
+	}
+}
+
+// String returns the environment variable k, or def when it is unset or
+// empty.
+func String(k, def string) string {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// Int returns the environment variable k as a positive integer, or def when
+// it is unset or not one.
+func Int(k string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(k))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// Duration returns the environment variable k as a duration such as "5s",
+// or def when it is unset or invalid.
+func Duration(k string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(k))
+	if err != nil {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation.
+const listenFDsStart = 3
+
+// activatedListeners collects sockets handed over through LISTEN_FDS, keyed
+// by their LISTEN_FDNAMES entry. An unnamed first socket is treated as
+// "http". The variables are consumed once so child processes don't inherit
+// them.
+var activatedListeners = sync.OnceValues(func() (map[string]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make(map[string]net.Listener, n)
+	for i := 0; i < n; i++ {
+		name := ""
+		if i < len(names) && names[i] != "unknown" {
+			name = names[i]
+		}
+		if name == "" && i == 0 {
+			name = "http"
+		}
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket activation fd %d: %w", listenFDsStart+i, err)
+		}
+		listeners[name] = l
+	}
+	return listeners, nil
+})
+
+// UnixAddrPrefix selects a Unix domain socket, e.g. unix:/run/codigo/api.sock.
+const UnixAddrPrefix = "unix:"
+
+// Listen returns the socket-activated listener called name when there is
+// one, and otherwise listens on addr. Addresses may name an interface
+// address, e.g. 10.0.0.5:8080 or [::1]:8080; an empty host such as :8080
+// listens on all IPv4 and IPv6 addresses. A unix: address listens on a Unix
+// socket, replacing a stale socket file left by a previous run; the file is
+// removed again when the listener is closed.
+func Listen(name, addr string) (net.Listener, error) {
+	activated, err := activatedListeners()
+	if err != nil {
+		return nil, err
+	}
+	if l, ok := activated[name]; ok {
+		return l, nil
+	}
+	if path, ok := strings.CutPrefix(addr, UnixAddrPrefix); ok {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove stale socket %s: %w", path, err)
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
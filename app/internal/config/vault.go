@@ -0,0 +1,180 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// serviceAccountTokenFile is the Kubernetes service account token used for
+// Vault's Kubernetes auth method.
+const serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Vault reads service credentials from HashiCorp Vault's HTTP API.
+type Vault struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// Secret is a Vault read or lease renewal response.
+type Secret struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+	Auth          *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+}
+
+// Field returns a string value from the secret, looking inside the nested
+// data object KV version 2 wraps values in.
+func (s *Secret) Field(name string) string {
+	if v, ok := s.Data[name].(string); ok {
+		return v
+	}
+	if inner, ok := s.Data["data"].(map[string]any); ok {
+		if v, ok := inner[name].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// NewVault returns nil when VAULT_ADDR is unset. It authenticates with
+// VAULT_TOKEN, the token in VAULT_TOKEN_FILE, or Kubernetes auth as
+// VAULT_K8S_ROLE.
+func NewVault(ctx context.Context) (*Vault, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return nil, nil
+	}
+	c := &Vault{addr: addr, token: os.Getenv("VAULT_TOKEN"), client: &http.Client{Timeout: 10 * time.Second}}
+	if f := os.Getenv("VAULT_TOKEN_FILE"); c.token == "" && f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read VAULT_TOKEN_FILE: %w", err)
+		}
+		c.token = strings.TrimSpace(string(b))
+	}
+	if role := os.Getenv("VAULT_K8S_ROLE"); c.token == "" && role != "" {
+		jwt, err := os.ReadFile(serviceAccountTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read service account token: %w", err)
+		}
+		mount := String("VAULT_K8S_MOUNT", "kubernetes")
+		secret, err := c.do(ctx, http.MethodPost, "auth/"+mount+"/login", map[string]string{"role": role, "jwt": string(jwt)})
+		if err != nil {
+			return nil, fmt.Errorf("vault kubernetes login: %w", err)
+		}
+		if secret.Auth == nil || secret.Auth.ClientToken == "" {
+			return nil, fmt.Errorf("vault kubernetes login returned no token")
+		}
+		c.token = secret.Auth.ClientToken
+	}
+	if c.token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is set but none of VAULT_TOKEN, VAULT_TOKEN_FILE or VAULT_K8S_ROLE is")
+	}
+	return c, nil
+}
+
+func (c *Vault) do(ctx context.Context, method, path string, body any) (*Secret, error) {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+"/v1/"+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("vault %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var secret Secret
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// Read fetches the secret at path, e.g. database/creds/codigo-api or
+// secret/data/codigo/postgres.
+func (c *Vault) Read(ctx context.Context, path string) (*Secret, error) {
+	return c.do(ctx, http.MethodGet, strings.TrimPrefix(path, "/"), nil)
+}
+
+// Watch reads the secret at path, passes it to update, and keeps it current
+// in the background: leased secrets are renewed at two thirds of their
+// duration, and re-read when renewal fails or the lease can't be extended
+// any further. Secrets without a lease are read once.
+func (c *Vault) Watch(ctx context.Context, path string, logger *zap.Logger, update func(*Secret)) error {
+	secret, err := c.Read(ctx, path)
+	if err != nil {
+		return err
+	}
+	update(secret)
+	if secret.LeaseDuration <= 0 {
+		return nil
+	}
+
+	go func() {
+		lease := secret
+		for {
+			wait := time.Duration(lease.LeaseDuration) * time.Second * 2 / 3
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			if lease.Renewable {
+				renewed, err := c.do(ctx, http.MethodPut, "sys/leases/renew", map[string]any{
+					"lease_id":  lease.LeaseID,
+					"increment": lease.LeaseDuration,
+				})
+				// A lease at its max TTL renews for less than asked;
+				// fetch fresh credentials before it runs out.
+				if err == nil && renewed.LeaseDuration >= lease.LeaseDuration/2 {
+					lease.LeaseDuration = renewed.LeaseDuration
+					continue
+				}
+				if err != nil {
+					logger.Warn("vault lease renewal failed", zap.String("path", path), zap.Error(err))
+				}
+			}
+
+			fresh, err := c.Read(ctx, path)
+			if err != nil {
+				logger.Error("vault secret refresh failed", zap.String("path", path), zap.Error(err))
+				lease = &Secret{LeaseDuration: 30}
+				continue
+			}
+			logger.Info("vault secret refreshed", zap.String("path", path))
+			update(fresh)
+			lease = fresh
+		}
+	}()
+	return nil
+}
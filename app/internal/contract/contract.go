@@ -0,0 +1,99 @@
+// Package contract is the job message the API publishes and the worker
+// consumes. Both binaries build and read job messages only through it, and
+// its golden files pin the wire format, so a publish change the worker
+// can't read fails the tests instead of stranding jobs in production.
+//
+// A job travels on queue.JobSubject(tenant, type). The headers carry the
+// job ID, the publisher's region, the publish time and any deadline or
+// priority; the body is the job's payload, sealed with the tenant's payload
+// key when it has one. The worker answers with queue.DispatchAccepted or
+// queue.DispatchBusy. Trace context is injected by the publisher on top.
+package contract
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"codigo/internal/queue"
+)
+
+// Job is what a job message carries.
+type Job struct {
+	ID     string
+	Tenant string
+	Type   string
+	// Region is the publisher's REGION, "" when unset.
+	Region string
+	// Priority is "high", "low" or "" for normal.
+	Priority string
+	// Deadline is zero for jobs that never expire.
+	Deadline time.Time
+	// PublishedAt is zero for messages of publishers that don't stamp it.
+	PublishedAt time.Time
+	// Payload is what the job works on, nil for jobs created without one.
+	Payload []byte
+}
+
+// Encode builds the message for j, sealing the payload with keys.
+func Encode(j Job, keys *queue.Keyring) (*nats.Msg, error) {
+	keyID, data, err := keys.Seal(j.Tenant, j.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt payload: %w", err)
+	}
+	headers := make(nats.Header)
+	headers.Set(queue.JobIDHeader, j.ID)
+	if j.Region != "" {
+		headers.Set(queue.RegionHeader, j.Region)
+	}
+	if j.Priority != "" {
+		headers.Set(queue.PriorityHeader, j.Priority)
+	}
+	if !j.Deadline.IsZero() {
+		headers.Set(queue.DeadlineHeader, j.Deadline.UTC().Format(time.RFC3339Nano))
+	}
+	if !j.PublishedAt.IsZero() {
+		headers.Set(queue.PublishedAtHeader, j.PublishedAt.UTC().Format(time.RFC3339Nano))
+	}
+	if keyID != "" {
+		headers.Set(queue.KeyIDHeader, keyID)
+		headers.Set(queue.CipherHeader, queue.Cipher)
+	}
+	return &nats.Msg{Subject: queue.JobSubject(j.Tenant, j.Type), Data: data, Header: headers}, nil
+}
+
+// Decode reads the job m carries, opening the body with keys. Messages of
+// publishers without the job ID header carry the ID as the body, and no
+// payload. When the body can't be opened, the error comes with whatever
+// the headers tell, ID included when present, so the job can still be
+// recorded as failed. Headers that don't parse read as absent.
+func Decode(m *nats.Msg, keys *queue.Keyring) (Job, error) {
+	j := Job{
+		ID:     m.Header.Get(queue.JobIDHeader),
+		Region: m.Header.Get(queue.RegionHeader),
+	}
+	j.Tenant, j.Type = queue.TenantType(m.Subject)
+	switch p := m.Header.Get(queue.PriorityHeader); p {
+	case "high", "low":
+		j.Priority = p
+	}
+	if deadline, ok := queue.Deadline(m); ok {
+		j.Deadline = deadline
+	}
+	if published, err := time.Parse(time.RFC3339Nano, m.Header.Get(queue.PublishedAtHeader)); err == nil {
+		j.PublishedAt = published
+	}
+
+	payload, err := keys.Open(m, j.Tenant)
+	if err != nil {
+		return j, err
+	}
+	switch {
+	case j.ID == "":
+		j.ID = string(payload)
+	case len(payload) > 0:
+		j.Payload = payload
+	}
+	return j, nil
+}
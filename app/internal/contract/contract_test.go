@@ -0,0 +1,247 @@
+package contract
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"codigo/internal/queue"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from Encode")
+
+var published = time.Date(2026, 3, 4, 5, 6, 7, 890000000, time.UTC)
+
+// goldenJobs are published by the API through Encode and must be read back
+// by the worker through Decode. Their golden files pin the wire format.
+var goldenJobs = []struct {
+	name string
+	job  Job
+}{
+	{"minimal", Job{ID: "job_1", Tenant: "acme", Type: "report", PublishedAt: published}},
+	{"default_tenant", Job{ID: "job_2", Tenant: queue.DefaultToken, Type: queue.DefaultToken, PublishedAt: published}},
+	{"full", Job{
+		ID:          "job_3",
+		Tenant:      "acme",
+		Type:        "export",
+		Region:      "eu-west-1",
+		Priority:    "high",
+		Deadline:    published.Add(90 * time.Second),
+		PublishedAt: published,
+		Payload:     []byte(`{"ref":"refs/heads/main","size":3}`),
+	}},
+}
+
+func TestEncodeMatchesGolden(t *testing.T) {
+	keys := loadKeyring(t, "", "")
+	for _, tt := range goldenJobs {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Encode(tt.job, keys)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			got := render(m)
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Encode() message differs from %s; run go test -update if the change is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeGolden(t *testing.T) {
+	keys := loadKeyring(t, "", "")
+	tests := []struct {
+		name string
+		want Job
+	}{
+		// legacy was published before the job ID header existed, with an
+		// unknown priority; it is only ever decoded.
+		{"legacy", Job{ID: "job_0", Tenant: "acme", Type: "report", PublishedAt: published}},
+	}
+	for _, tt := range goldenJobs {
+		tests = append(tests, struct {
+			name string
+			want Job
+		}{tt.name, tt.job})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := parse(t, filepath.Join("testdata", tt.name+".golden"))
+			got, err := Decode(m, keys)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	keys := loadKeyring(t, "k1="+key, "acme=k1")
+	job := goldenJobs[2].job
+
+	m, err := Encode(job, keys)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if m.Header.Get(queue.KeyIDHeader) != "k1" || m.Header.Get(queue.CipherHeader) != queue.Cipher {
+		t.Fatalf("Encode() headers = %v, want key k1 and cipher %s", m.Header, queue.Cipher)
+	}
+	if bytes.Contains(m.Data, job.Payload) {
+		t.Fatalf("Encode() body %q holds the payload in clear", m.Data)
+	}
+	got, err := Decode(m, keys)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, job) {
+		t.Errorf("Decode() = %+v, want %+v", got, job)
+	}
+
+	// A worker without the key still learns which job it couldn't open.
+	got, err = Decode(m, loadKeyring(t, "", ""))
+	if err == nil {
+		t.Fatal("Decode() without the key succeeded")
+	}
+	if got.ID != job.ID || got.Tenant != job.Tenant || got.Type != job.Type {
+		t.Errorf("Decode() without the key = %+v, want ID, tenant and type of %+v", got, job)
+	}
+
+	// The body is bound to its tenant.
+	m.Subject = queue.JobSubject("globex", job.Type)
+	if _, err := Decode(m, keys); err == nil {
+		t.Error("Decode() of a body moved to another tenant succeeded")
+	}
+}
+
+func loadKeyring(t *testing.T, keys, tenants string) *queue.Keyring {
+	t.Helper()
+	t.Setenv("PAYLOAD_KEYS", keys)
+	t.Setenv("TENANT_PAYLOAD_KEYS", tenants)
+	kr, err := queue.LoadKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+// render writes m as its subject, its headers in name order and its
+// quoted body, one per line.
+func render(m *nats.Msg) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "subject: %s\n", m.Subject)
+	names := make([]string, 0, len(m.Header))
+	for name := range m.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range m.Header[name] {
+			fmt.Fprintf(&b, "header: %s: %s\n", name, v)
+		}
+	}
+	fmt.Fprintf(&b, "body: %q\n", m.Data)
+	return b.Bytes()
+}
+
+// parse reads a message written by render.
+func parse(t *testing.T, path string) *nats.Msg {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m := &nats.Msg{Header: make(nats.Header)}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "subject":
+			m.Subject = value
+		case "header":
+			name, v, _ := strings.Cut(value, ": ")
+			m.Header.Add(name, v)
+		case "body":
+			body, err := strconv.Unquote(value)
+			if err != nil {
+				t.Fatalf("%s: invalid body %s: %v", path, value, err)
+			}
+			m.Data = []byte(body)
+		default:
+			t.Fatalf("%s: unexpected line %q", path, scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, tt := range goldenJobs {
+		f.Add(tt.job.ID, tt.job.Tenant, tt.job.Type, tt.job.Priority, tt.job.Payload)
+	}
+	f.Add("", "acme", "report", "urgent", []byte("job_0"))
+	f.Add("job_4", "a.b", "*", "", []byte(nil))
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	f.Fuzz(func(t *testing.T, id, tenant, jobType, priority string, payload []byte) {
+		keys := loadKeyring(t, "k1="+key, "acme=k1")
+		// Whatever arrives on a jobs subject must decode without panicking.
+		m := &nats.Msg{Subject: "jobs." + tenant + "." + jobType, Data: payload, Header: make(nats.Header)}
+		m.Header.Set(queue.JobIDHeader, id)
+		m.Header.Set(queue.PriorityHeader, priority)
+		m.Header.Set(queue.CipherHeader, priority)
+		Decode(m, keys)
+
+		// What the API can publish must come back unchanged.
+		if id == "" || !queue.ValidToken(tenant) || !queue.ValidToken(jobType) {
+			return
+		}
+		job := Job{ID: id, Tenant: tenant, Type: jobType, PublishedAt: published}
+		switch priority {
+		case "high", "low":
+			job.Priority = priority
+		}
+		if len(payload) > 0 {
+			job.Payload = payload
+		}
+		m, err := Encode(job, keys)
+		if err != nil {
+			t.Fatalf("Encode(%+v) error = %v", job, err)
+		}
+		got, err := Decode(m, keys)
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, job) {
+			t.Errorf("Decode(Encode(%+v)) = %+v", job, got)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Package errs maps Postgres and NATS errors to a small set of domain error
+// kinds, so HTTP handlers and retry loops decide what to do from the kind
+// instead of matching driver errors themselves.
+package errs
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nats-io/nats.go"
+)
+
+// Kind is the class of a failure.
+type Kind string
+
+const (
+	// Internal is anything not classified below: a bug or misconfiguration
+	// that retrying won't fix.
+	Internal Kind = "internal"
+	// NotFound means the row asked for does not exist.
+	NotFound Kind = "not_found"
+	// Conflict means a uniqueness constraint rejected the write.
+	Conflict Kind = "conflict"
+	// Invalid means the data was rejected by a constraint or was too large
+	// to publish.
+	Invalid Kind = "invalid"
+	// Contention means Postgres aborted the transaction with a
+	// serialization failure or deadlock; rerunning it may succeed.
+	Contention Kind = "contention"
+	// Unavailable means Postgres or NATS could not be reached or answered
+	// too slowly; the same operation may succeed later.
+	Unavailable Kind = "unavailable"
+)
+
+// Error is a failure of Op, classified as Kind.
+type Error struct {
+	Kind Kind
+	Op   string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap classifies err and annotates it with op. It returns nil for a nil
+// err.
+func Wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: KindOf(err), Op: op, Err: err}
+}
+
+// KindOf returns the kind of err: the kind of the outermost *Error in its
+// chain, or else a classification of the driver error inside.
+func KindOf(err error) Kind {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if k, ok := pgKind(err); ok {
+		return k
+	}
+	if k, ok := natsKind(err); ok {
+		return k
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return Unavailable
+	}
+	return Internal
+}
+
+// Retryable reports whether repeating the failed operation may succeed.
+func Retryable(err error) bool {
+	k := KindOf(err)
+	return k == Contention || k == Unavailable
+}
+
+// Is reports whether err is of kind k.
+func Is(err error, k Kind) bool {
+	return err != nil && KindOf(err) == k
+}
+
+// pgKind classifies pgx errors by SQLSTATE and connection failure.
+func pgKind(err error) (Kind, bool) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NotFound, true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "23505": // unique_violation
+			return Conflict, true
+		case pgErr.Code == "40001", pgErr.Code == "40P01": // serialization_failure, deadlock_detected
+			return Contention, true
+		case pgErr.Code == "57014", // query_canceled, e.g. statement_timeout
+			pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03", // shutdown, cannot_connect_now
+			strings.HasPrefix(pgErr.Code, "08"), // connection_exception
+			strings.HasPrefix(pgErr.Code, "53"): // insufficient_resources
+			return Unavailable, true
+		case strings.HasPrefix(pgErr.Code, "22"), strings.HasPrefix(pgErr.Code, "23"): // data_exception, integrity_constraint_violation
+			return Invalid, true
+		}
+		return Internal, true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.Timeout(err) || pgconn.SafeToRetry(err) {
+		return Unavailable, true
+	}
+	return "", false
+}
+
+// natsKind classifies nats.go errors.
+func natsKind(err error) (Kind, bool) {
+	switch {
+	case errors.Is(err, nats.ErrNoServers),
+		errors.Is(err, nats.ErrConnectionClosed),
+		errors.Is(err, nats.ErrConnectionDraining),
+		errors.Is(err, nats.ErrConnectionReconnecting),
+		errors.Is(err, nats.ErrDisconnected),
+		errors.Is(err, nats.ErrStaleConnection),
+		errors.Is(err, nats.ErrReconnectBufExceeded),
+		errors.Is(err, nats.ErrMaxConnectionsExceeded),
+		errors.Is(err, nats.ErrTimeout),
+		errors.Is(err, nats.ErrNoResponders),
+		errors.Is(err, nats.ErrSlowConsumer):
+		return Unavailable, true
+	case errors.Is(err, nats.ErrMaxPayload),
+		errors.Is(err, nats.ErrBadSubject),
+		errors.Is(err, nats.ErrInvalidMsg),
+		errors.Is(err, nats.ErrBadHeaderMsg):
+		return Invalid, true
+	}
+	return "", false
+}
@@ -0,0 +1,105 @@
+package fakes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestPostgresPool(t *testing.T) {
+	ctx := context.Background()
+	db, err := PostgresPool(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = 5"); err != nil {
+		t.Errorf("SET error = %v", err)
+	}
+	tag, err := tx.Exec(ctx, `INSERT INTO jobs (id, tenant) VALUES ($1, $2)`, "job_1", "acme")
+	if err != nil || tag.RowsAffected() != 1 {
+		t.Errorf("INSERT = %v, %v, want one row", tag, err)
+	}
+	var n int
+	err = tx.QueryRow(ctx, `INSERT INTO job_attempts (started_at, wall_seconds) VALUES ($1, $2) RETURNING attempt`, time.Now(), 1.5).Scan(&n)
+	if err != nil || n != 1 {
+		t.Errorf("INSERT ... RETURNING = %d, %v, want 1", n, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Errorf("Commit() error = %v", err)
+	}
+}
+
+func TestNATSRequestReply(t *testing.T) {
+	s := NewNATS()
+	worker, err := s.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer worker.Close()
+	api, err := s.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+
+	// Two members of one queue group: each request reaches one of them.
+	var got []string
+	for range 2 {
+		_, err := worker.QueueSubscribe("jobs.*.report", "workers", func(m *nats.Msg) {
+			got = append(got, m.Header.Get("Codigo-Job-Id"))
+			m.Respond([]byte("accepted"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := worker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &nats.Msg{Subject: "jobs.acme.report", Data: []byte("{}"), Header: nats.Header{"Codigo-Job-Id": {"job_1"}}}
+	reply, err := api.RequestMsg(msg, time.Second)
+	if err != nil {
+		t.Fatalf("RequestMsg() error = %v", err)
+	}
+	if string(reply.Data) != "accepted" {
+		t.Errorf("reply = %q, want accepted", reply.Data)
+	}
+	if len(got) != 1 || got[0] != "job_1" {
+		t.Errorf("delivered job IDs = %v, want job_1 once", got)
+	}
+	if _, err := api.Request("jobs.acme.export", nil, 50*time.Millisecond); err == nil {
+		t.Error("request on a subject nobody subscribes to was answered")
+	}
+}
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"jobs.acme.report", "jobs.acme.report", true},
+		{"jobs.*.report", "jobs.acme.report", true},
+		{"jobs.>", "jobs.acme.report", true},
+		{"jobs.>", "jobs", false},
+		{"jobs.*", "jobs.acme.report", false},
+		{"jobs.acme.report", "jobs.acme", false},
+	}
+	for _, tt := range tests {
+		if got := subjectMatches(tt.pattern, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package fakes runs NATS and Postgres in memory for benchmarks. Each
+// speaks enough of its wire protocol for the real clients, nats.go and
+// pgx, so benchmarks measure the code paths production runs without a
+// broker or database to set up.
+package fakes
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS is an in-memory NATS server. It routes messages between the
+// connections made with Connect, honouring wildcards and queue groups, and
+// nothing more: no JetStream, no auth and no no-responders status.
+type NATS struct {
+	mu   sync.Mutex
+	subs []*subscription
+	turn int // picks the queue group member of the next message
+}
+
+type subscription struct {
+	client  *client
+	subject string
+	queue   string
+	sid     string
+}
+
+type client struct {
+	mu   sync.Mutex // serializes writes
+	conn net.Conn
+}
+
+// NewNATS returns a server without connections.
+func NewNATS() *NATS {
+	return &NATS{}
+}
+
+// Connect opens a client connection to s.
+func (s *NATS) Connect(opts ...nats.Option) (*nats.Conn, error) {
+	return nats.Connect("", append(opts, nats.InProcessServer(s))...)
+}
+
+// InProcessConn implements nats.InProcessConnProvider.
+func (s *NATS) InProcessConn() (net.Conn, error) {
+	server, conn := net.Pipe()
+	c := &client{conn: server}
+	go func() {
+		c.write(`INFO {"server_id":"fake","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}` + "\r\n")
+		s.serve(c)
+	}()
+	return conn, nil
+}
+
+func (c *client) write(parts ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range parts {
+		if _, err := io.WriteString(c.conn, p); err != nil {
+			return
+		}
+	}
+}
+
+// serve reads c's protocol lines until the connection closes.
+func (s *NATS) serve(c *client) {
+	defer s.drop(c)
+	r := bufio.NewReader(c.conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		op, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		f := strings.Fields(args)
+		switch strings.ToUpper(op) {
+		case "CONNECT", "PONG":
+		case "PING":
+			c.write("PONG\r\n")
+		case "SUB":
+			// SUB <subject> [queue] <sid>
+			sub := &subscription{client: c, subject: f[0], sid: f[len(f)-1]}
+			if len(f) == 3 {
+				sub.queue = f[1]
+			}
+			s.mu.Lock()
+			s.subs = append(s.subs, sub)
+			s.mu.Unlock()
+		case "UNSUB":
+			// A max count is ignored: the client drops what it doesn't want.
+			s.unsubscribe(c, f[0])
+		case "PUB", "HPUB":
+			// PUB <subject> [reply] <size>
+			// HPUB <subject> [reply] <header size> <size>
+			size, err := strconv.Atoi(f[len(f)-1])
+			if err != nil {
+				return
+			}
+			f = f[:len(f)-1]
+			hdrSize := -1
+			if strings.EqualFold(op, "HPUB") {
+				if hdrSize, err = strconv.Atoi(f[len(f)-1]); err != nil {
+					return
+				}
+				f = f[:len(f)-1]
+			}
+			reply := ""
+			if len(f) == 2 {
+				reply = f[1]
+			}
+			data := make([]byte, size+2) // payload and its CRLF
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			s.route(f[0], reply, hdrSize, data[:size])
+		default:
+			c.write("-ERR 'Unknown Protocol Operation'\r\n")
+			return
+		}
+	}
+}
+
+// route delivers a message to every matching subscription outside a queue
+// group and to one member of each matching queue group. hdrSize is -1 for
+// messages without headers.
+func (s *NATS) route(subject, reply string, hdrSize int, data []byte) {
+	s.mu.Lock()
+	var targets []*subscription
+	groups := map[string][]*subscription{}
+	for _, sub := range s.subs {
+		if !subjectMatches(sub.subject, subject) {
+			continue
+		}
+		if sub.queue == "" {
+			targets = append(targets, sub)
+		} else {
+			groups[sub.queue] = append(groups[sub.queue], sub)
+		}
+	}
+	for _, members := range groups {
+		targets = append(targets, members[s.turn%len(members)])
+	}
+	s.turn++
+	s.mu.Unlock()
+
+	for _, sub := range targets {
+		head := "MSG " + subject + " " + sub.sid + " "
+		if reply != "" {
+			head += reply + " "
+		}
+		if hdrSize >= 0 {
+			head = "H" + head + strconv.Itoa(hdrSize) + " "
+		}
+		sub.client.write(head+strconv.Itoa(len(data))+"\r\n", string(data), "\r\n")
+	}
+}
+
+func (s *NATS) unsubscribe(c *client, sid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sub := range s.subs {
+		if sub.client == c && sub.sid == sid {
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// drop removes the subscriptions of a closed connection.
+func (s *NATS) drop(c *client) {
+	c.conn.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.subs[:0]
+	for _, sub := range s.subs {
+		if sub.client != c {
+			kept = append(kept, sub)
+		}
+	}
+	s.subs = kept
+}
+
+// subjectMatches reports whether subject matches pattern, which may use
+// the * and > wildcards.
+func subjectMatches(pattern, subject string) bool {
+	pt, st := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, t := range pt {
+		switch {
+		case t == ">":
+			return i < len(st)
+		case i >= len(st):
+			return false
+		case t != "*" && t != st[i]:
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
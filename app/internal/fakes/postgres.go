@@ -0,0 +1,197 @@
+package fakes
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresPool opens a pool whose connections each reach an in-memory
+// Postgres server that accepts every statement and changes nothing.
+// Statements succeed with a row count of one and return no rows, except
+// that one with a RETURNING clause returns a single row holding the int8
+// 1, which covers inserts that report an ID or counter. Parameters are
+// untyped, as for a server that infers them. tune, which may be nil,
+// adjusts the pool's config first, e.g. to add the tracer storage.Open
+// sets.
+func PostgresPool(ctx context.Context, tune func(*pgxpool.Config)) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig("postgres://fake@fake/fake?sslmode=disable")
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConnConfig.LookupFunc = func(context.Context, string) ([]string, error) {
+		return []string{"fake"}, nil
+	}
+	cfg.ConnConfig.DialFunc = func(context.Context, string, string) (net.Conn, error) {
+		server, conn := net.Pipe()
+		go serveConn(server)
+		return conn, nil
+	}
+	if tune != nil {
+		tune(cfg)
+	}
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+	returningPattern   = regexp.MustCompile(`(?i)\bRETURNING\b`)
+)
+
+// statement is a parsed statement as the fake sees it.
+type statement struct {
+	sql       string
+	params    int
+	returning bool
+}
+
+func parse(sql string) statement {
+	st := statement{sql: sql, returning: returningPattern.MatchString(sql)}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(sql, -1) {
+		if n, _ := strconv.Atoi(m[1]); n > st.params {
+			st.params = n
+		}
+	}
+	return st
+}
+
+// rowDescription describes st's rows, in format once they are bound.
+func (st statement) rowDescription(format int16) pgproto3.BackendMessage {
+	if !st.returning {
+		return &pgproto3.NoData{}
+	}
+	return &pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{
+		Name:         []byte("returning"),
+		DataTypeOID:  pgtype.Int8OID,
+		DataTypeSize: 8,
+		TypeModifier: -1,
+		Format:       format,
+	}}}
+}
+
+// commandTag is what Postgres reports for st when it touches one row.
+func (st statement) commandTag() []byte {
+	verb, _, _ := strings.Cut(strings.TrimSpace(st.sql), " ")
+	switch verb = strings.ToUpper(verb); verb {
+	case "INSERT":
+		return []byte("INSERT 0 1")
+	case "UPDATE", "DELETE", "SELECT":
+		return []byte(verb + " 1")
+	default:
+		return []byte(verb)
+	}
+}
+
+// portal is a bound statement.
+type portal struct {
+	statement
+	format int16 // of the result
+}
+
+// serveConn speaks the Postgres protocol on conn until the client leaves.
+func serveConn(conn net.Conn) {
+	defer conn.Close()
+	b := pgproto3.NewBackend(conn, conn)
+	for {
+		msg, err := b.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*pgproto3.StartupMessage); ok {
+			break
+		}
+		// Decline SSL and GSS encryption requests.
+		if _, err := conn.Write([]byte("N")); err != nil {
+			return
+		}
+	}
+	b.Send(&pgproto3.AuthenticationOk{})
+	b.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: "16.0"})
+	b.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+	b.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+	b.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	b.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if b.Flush() != nil {
+		return
+	}
+
+	txStatus := byte('I')
+	statements := map[string]statement{}
+	portals := map[string]portal{}
+	for {
+		msg, err := b.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			st := parse(strings.Trim(msg.String, "; \t\n"))
+			tag := st.commandTag()
+			switch string(tag) {
+			case "":
+				// Ping sends an empty query.
+				b.Send(&pgproto3.EmptyQueryResponse{})
+			case "BEGIN":
+				txStatus = 'T'
+			case "COMMIT", "ROLLBACK":
+				txStatus = 'I'
+			}
+			if len(tag) > 0 {
+				b.Send(&pgproto3.CommandComplete{CommandTag: tag})
+			}
+			b.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+			if b.Flush() != nil {
+				return
+			}
+		case *pgproto3.Parse:
+			statements[msg.Name] = parse(msg.Query)
+			b.Send(&pgproto3.ParseComplete{})
+		case *pgproto3.Describe:
+			if msg.ObjectType == 'S' {
+				st := statements[msg.Name]
+				b.Send(&pgproto3.ParameterDescription{ParameterOIDs: make([]uint32, st.params)})
+				b.Send(st.rowDescription(pgproto3.TextFormat))
+			} else {
+				p := portals[msg.Name]
+				b.Send(p.rowDescription(p.format))
+			}
+		case *pgproto3.Bind:
+			p := portal{statement: statements[msg.PreparedStatement]}
+			if len(msg.ResultFormatCodes) > 0 {
+				p.format = msg.ResultFormatCodes[0]
+			}
+			portals[msg.DestinationPortal] = p
+			b.Send(&pgproto3.BindComplete{})
+		case *pgproto3.Execute:
+			p := portals[msg.Portal]
+			if p.returning {
+				value := []byte("1")
+				if p.format == pgproto3.BinaryFormat {
+					value = binary.BigEndian.AppendUint64(nil, 1)
+				}
+				b.Send(&pgproto3.DataRow{Values: [][]byte{value}})
+			}
+			b.Send(&pgproto3.CommandComplete{CommandTag: p.commandTag()})
+		case *pgproto3.Close:
+			b.Send(&pgproto3.CloseComplete{})
+		case *pgproto3.Sync:
+			b.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+			if b.Flush() != nil {
+				return
+			}
+		case *pgproto3.Flush:
+			if b.Flush() != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+
+	"codigo/internal/queue"
+)
+
+// CompletedSubject carries a Completed event for every recorded result, for
+// services that act on job completion without reading the database.
+const CompletedSubject = "jobs.completed"
+
+// CompletedSchema names the version of Completed, described by
+// completed.schema.json. Within a version fields are only ever added;
+// removing or changing one means a new version.
+const CompletedSchema = "codigo.jobs.completed/v1"
+
+// SchemaHeader names the schema of an event's payload.
+const SchemaHeader = "Codigo-Schema"
+
+// Completed is the event published once a job attempt's result is
+// persisted.
+type Completed struct {
+	Schema  string `json:"schema"`
+	JobID   string `json:"job_id"`
+	Tenant  string `json:"tenant"`
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Attempt int    `json:"attempt"`
+	// ResultRef is the API path serving the job's status and timeline.
+	ResultRef  string    `json:"result_ref"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// CompletionStream publishes Completed events into a JetStream stream, so
+// consumers that are down catch up instead of missing events. A nil
+// *CompletionStream publishes nothing.
+type CompletionStream struct {
+	js nats.JetStreamContext
+}
+
+// NewCompletionStream returns a publisher into stream, creating the stream
+// on CompletedSubject with maxAge retention when it doesn't exist yet.
+func NewCompletionStream(nc *nats.Conn, stream string, maxAge time.Duration) (*CompletionStream, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := js.StreamInfo(stream); errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     stream,
+			Subjects: []string{CompletedSubject},
+			MaxAge:   maxAge,
+		})
+		if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+			return nil, fmt.Errorf("failed to create stream %s: %w", stream, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to look up stream %s: %w", stream, err)
+	}
+	return &CompletionStream{js: js}, nil
+}
+
+// Publish publishes the event for res, persisted as attempt. The message ID
+// lets the stream drop a republished event within its duplicate window.
+func (cs *CompletionStream) Publish(ctx context.Context, res Result, attempt int) error {
+	if cs == nil {
+		return nil
+	}
+	data, err := json.Marshal(Completed{
+		Schema:     CompletedSchema,
+		JobID:      res.JobID,
+		Tenant:     res.Tenant,
+		Type:       res.Type,
+		Status:     res.Status,
+		Error:      res.Error,
+		Attempt:    attempt,
+		ResultRef:  "/v1/jobs/" + res.JobID,
+		TraceID:    res.TraceID,
+		FinishedAt: res.FinishedAt,
+	})
+	if err != nil {
+		return err
+	}
+	headers := make(nats.Header)
+	otel.GetTextMapPropagator().Inject(ctx, queue.HeaderCarrier(headers))
+	headers.Set(SchemaHeader, CompletedSchema)
+	headers.Set(nats.MsgIdHdr, fmt.Sprintf("%s/%d", res.JobID, attempt))
+	_, err = cs.js.PublishMsg(&nats.Msg{
+		Subject: CompletedSubject,
+		Data:    data,
+		Header:  headers,
+	}, nats.Context(ctx))
+	return err
+}
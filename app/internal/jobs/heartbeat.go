@@ -0,0 +1,19 @@
+package jobs
+
+import "time"
+
+// HeartbeatSubject is where workers announce themselves; every API replica
+// listens and keeps its own view of the fleet.
+const HeartbeatSubject = "workers.heartbeat"
+
+// Heartbeat is the registration record a worker publishes periodically.
+type Heartbeat struct {
+	Instance    string    `json:"instance"`
+	Version     string    `json:"version"`
+	Region      string    `json:"region,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	Subjects    []string  `json:"subjects"`
+	Concurrency int       `json:"concurrency"`
+	InFlight    int64     `json:"in_flight"`
+	SentAt      time.Time `json:"sent_at"`
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogBuffer keeps the log lines of one job attempt, up to a byte limit.
+// Lines past the limit are counted but not kept, so a chatty handler can't
+// bloat job_attempts or the result event.
+type LogBuffer struct {
+	limit int
+
+	mu      sync.Mutex
+	b       strings.Builder
+	dropped int
+}
+
+// NewLogBuffer returns a buffer keeping at most limit bytes of whole lines.
+func NewLogBuffer(limit int) *LogBuffer {
+	return &LogBuffer{limit: limit}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dropped > 0 || b.b.Len()+len(p) > b.limit {
+		b.dropped += len(p)
+		return len(p), nil
+	}
+	b.b.Write(p)
+	return len(p), nil
+}
+
+func (b *LogBuffer) Sync() error { return nil }
+
+// String returns the kept lines, ending with a marker that says how much
+// was dropped when the limit was reached.
+func (b *LogBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.dropped == 0 {
+		return b.b.String()
+	}
+	return b.b.String() + fmt.Sprintf("... [dropped %d bytes of logs]\n", b.dropped)
+}
+
+// CaptureLogs returns logger with every entry also written to buf as a JSON
+// line. Entries below info are not captured.
+func CaptureLogs(logger *zap.Logger, buf *LogBuffer) *zap.Logger {
+	capture := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), buf, zapcore.InfoLevel)
+	return logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, capture)
+	}))
+}
+
+type loggerKey struct{}
+
+// WithLogger returns ctx carrying the job-scoped logger handlers log
+// through.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the job-scoped logger in ctx. What handlers log through it
+// is stored with the attempt and served at GET /v1/jobs/{id}/logs. Outside a
+// job it returns a no-op logger.
+func Logger(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return zap.NewNop()
+}
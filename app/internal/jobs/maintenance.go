@@ -0,0 +1,20 @@
+package jobs
+
+import "time"
+
+// MaintenanceSubject carries the maintenance state. API replicas announce it
+// while maintenance is on and whenever an admin changes it; the other
+// replicas and the workers follow.
+const MaintenanceSubject = "admin.maintenance"
+
+// Maintenance is the maintenance state: while Enabled the API refuses new
+// jobs and workers stop taking jobs, for planned database work.
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+	// Until is when the window is planned to end; clients are told to
+	// retry then.
+	Until *time.Time `json:"until,omitempty"`
+	// SetAt orders announcements, so a late one can't undo a newer change.
+	SetAt time.Time `json:"set_at"`
+}
@@ -0,0 +1,18 @@
+package jobs
+
+import "context"
+
+type payloadKey struct{}
+
+// WithPayload returns ctx carrying the payload the job was created with.
+func WithPayload(ctx context.Context, payload []byte) context.Context {
+	return context.WithValue(ctx, payloadKey{}, payload)
+}
+
+// Payload returns the payload of the job in ctx, such as the body of the
+// webhook it was ingested from. Jobs created without one, and code running
+// outside a job, get nil.
+func Payload(ctx context.Context) []byte {
+	payload, _ := ctx.Value(payloadKey{}).([]byte)
+	return payload
+}
@@ -0,0 +1,54 @@
+// Package jobs defines the records the worker sends the API about jobs: the
+// result of each processing attempt and the worker heartbeat. Either side
+// may write a result to Postgres and announce it on jobs.completed, so
+// recording and publishing it live here too.
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Result is the completion event workers publish to the results subject.
+type Result struct {
+	JobID      string  `json:"job_id"`
+	Tenant     string  `json:"tenant"`
+	Type       string  `json:"type"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	CPUMs      float64 `json:"cpu_ms"`
+	Error      string  `json:"error,omitempty"`
+
+	// Attempt details, recorded in job_attempts
+	Worker     string    `json:"worker"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	// Logs are the lines the handler logged through Logger, as JSON lines.
+	Logs string `json:"logs,omitempty"`
+}
+
+// recordAttemptSQL stores one processing attempt and returns its number;
+// attempts are numbered per job in the order they are recorded. Its tenant,
+// type, wall and CPU time are kept with it for chargeback, which sums them
+// at read time rather than bumping a shared per-day row on every result.
+const recordAttemptSQL = `
+	INSERT INTO job_attempts (job_id, attempt, worker, trace_id, started_at, finished_at, status, error, logs, tenant, type, wall_seconds, cpu_seconds)
+	SELECT $1, coalesce(max(attempt), 0) + 1, $2, $3, $4, $5, $6, nullif($7, ''), nullif($8, ''), $9, $10, $11, $12
+	FROM job_attempts WHERE job_id = $1
+	RETURNING attempt`
+
+// Record applies res to the job's status and its attempt history, logs and
+// costs included, in tx. It returns the attempt number.
+func Record(ctx context.Context, tx pgx.Tx, res Result) (int, error) {
+	if _, err := tx.Exec(ctx, `UPDATE jobs SET status=$2 WHERE id=$1`, res.JobID, res.Status); err != nil {
+		return 0, err
+	}
+	var attempt int
+	err := tx.QueryRow(ctx, recordAttemptSQL, res.JobID, res.Worker, res.TraceID, res.StartedAt, res.FinishedAt, res.Status, res.Error, res.Logs,
+		res.Tenant, res.Type, res.DurationMs/1000, res.CPUMs/1000).Scan(&attempt)
+	return attempt, err
+}
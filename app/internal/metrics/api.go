@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// API are the metrics of the API binary.
+type API struct {
+	Common
+
+	HTTPRequests          *prometheus.CounterVec
+	HTTPLatency           *prometheus.HistogramVec
+	HTTPInFlight          *prometheus.GaugeVec
+	HTTPConnections       *prometheus.GaugeVec
+	HTTPConnectionsOpened *prometheus.CounterVec
+
+	ClientRequests         *prometheus.CounterVec
+	ClientRequestsInFlight *prometheus.GaugeVec
+
+	NATSMessagesPublished *prometheus.CounterVec
+	NATSPublishDuration   *prometheus.HistogramVec
+	NATSPublishErrors     *prometheus.CounterVec
+
+	JobsArchived       *prometheus.CounterVec
+	JobResultsRecorded *prometheus.CounterVec
+	WebhooksReceived   *prometheus.CounterVec
+
+	JobCohortJobs        *prometheus.GaugeVec
+	JobCohortSuccessRate *prometheus.GaugeVec
+	JobCohortLatency     *prometheus.GaugeVec
+
+	SLOEvaluations *prometheus.CounterVec
+	SLOBudgetLeft  *prometheus.GaugeVec
+	SLOBurnRate    *prometheus.GaugeVec
+}
+
+// NewAPI creates the API metrics and registers them on reg.
+func NewAPI(reg prometheus.Registerer) *API {
+	f := promauto.With(reg)
+	return &API{
+		Common: newCommon(f),
+		HTTPRequests: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "http_requests_total",
+			Help:      "Total HTTP requests",
+		}, []string{"service", "route", "method", "code", "tenant", "type"}),
+		HTTPLatency: f.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"service", "route", "method", "tenant"}),
+		HTTPInFlight: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served",
+		}, []string{"service", "route", "method"}),
+		HTTPConnections: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "http_connections",
+			Help:      "Client connections to the API by state",
+		}, []string{"service", "state"}),
+		HTTPConnectionsOpened: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "http_connections_opened_total",
+			Help:      "Total client connections accepted by the API",
+		}, []string{"service"}),
+		ClientRequests: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "client_requests_total",
+			Help:      "HTTP requests per client by outcome; clients outside the top K are recorded as other",
+		}, []string{"service", "client", "result"}),
+		ClientRequestsInFlight: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "client_requests_in_flight",
+			Help:      "HTTP requests per client currently being served; clients outside the top K are recorded as other",
+		}, []string{"service", "client"}),
+		NATSMessagesPublished: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "nats_messages_published_total",
+			Help:      "Total NATS messages published",
+		}, []string{"service", "subject"}),
+		NATSPublishDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "nats_publish_duration_seconds",
+			Help:      "NATS publish latency",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"service", "subject"}),
+		NATSPublishErrors: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "nats_publish_errors_total",
+			Help:      "Total NATS publish failures",
+		}, []string{"service", "subject"}),
+		JobsArchived: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "jobs_archived_total",
+			Help:      "Total terminal jobs moved to jobs_history",
+		}, []string{"service"}),
+		JobResultsRecorded: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "job_results_recorded_total",
+			Help:      "Total worker result events recorded by the API",
+		}, []string{"service", "result"}),
+		WebhooksReceived: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "webhooks_received_total",
+			Help:      "Inbound webhooks by source and outcome",
+		}, []string{"service", "source", "result"}),
+		JobCohortJobs: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "job_cohort_jobs",
+			Help:      "Terminal jobs created within the cohort window, from the jobs table",
+		}, []string{"service", "window", "status"}),
+		JobCohortSuccessRate: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "job_cohort_success_ratio",
+			Help:      "Fraction of terminal jobs created within the cohort window that succeeded, from the jobs table",
+		}, []string{"service", "window"}),
+		JobCohortLatency: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "job_cohort_latency_seconds",
+			Help:      "End-to-end latency quantiles of successful jobs created within the cohort window, from the jobs table",
+		}, []string{"service", "window", "quantile"}),
+		SLOEvaluations: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "slo_evaluations_total",
+			Help:      "Embedded SLO evaluation runs by outcome",
+		}, []string{"service", "result"}),
+		SLOBudgetLeft: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "slo_error_budget_left",
+			Help:      "Fraction of the error budget left over the SLO window, from the embedded evaluation",
+		}, []string{"service", "method", "route", "kind"}),
+		SLOBurnRate: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "slo_burn_rate",
+			Help:      "Error budget burn rate over the SLO window, from the embedded evaluation",
+		}, []string{"service", "method", "route", "kind"}),
+	}
+}
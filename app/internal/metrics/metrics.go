@@ -0,0 +1,195 @@
+// Package metrics defines every Prometheus metric the API and worker
+// export. Constructors register on the registry they are given, so each
+// binary owns one registry and tests can build an isolated one.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric name.
+const Namespace = "codigo"
+
+// Common are the metrics both binaries export. Every metric carries a
+// service label with the SERVICE_NAME of the binary.
+type Common struct {
+	DBConnections    *prometheus.GaugeVec
+	DBMaxConnections *prometheus.GaugeVec
+	DBEmptyAcquires  *prometheus.CounterVec
+	DBTxDuration     *prometheus.HistogramVec
+
+	JobCompletedEvents *prometheus.CounterVec
+	MaintenanceMode    *prometheus.GaugeVec
+
+	OTelSpansEnded    *prometheus.CounterVec
+	OTelSpansExported *prometheus.CounterVec
+	OTelSpansDropped  *prometheus.CounterVec
+}
+
+func newCommon(f promauto.Factory) Common {
+	return Common{
+		DBConnections: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "db_connections_active",
+			Help:      "Active database connections per pool",
+		}, []string{"service", "pool"}),
+		DBMaxConnections: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "db_connections_max",
+			Help:      "Maximum database connections per pool",
+		}, []string{"service", "pool"}),
+		DBEmptyAcquires: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "db_pool_empty_acquires_total",
+			Help:      "Connection acquisitions that had to wait because every connection of the pool was in use",
+		}, []string{"service", "pool"}),
+		DBTxDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "db_tx_duration_seconds",
+			Help:      "Database transaction duration including retries",
+			Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"service", "result"}),
+		JobCompletedEvents: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "job_completed_events_total",
+			Help:      "Job completion events published to the jobs.completed stream by outcome",
+		}, []string{"service", "result"}),
+		MaintenanceMode: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "maintenance_mode",
+			Help:      "1 while a maintenance window pauses job creation and consumption, 0 otherwise",
+		}, []string{"service"}),
+		OTelSpansEnded: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "otel_spans_ended_total",
+			Help:      "Total sampled spans ended, before any drop",
+		}, []string{"service"}),
+		OTelSpansExported: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "otel_spans_exported_total",
+			Help:      "Total spans successfully exported",
+		}, []string{"service"}),
+		OTelSpansDropped: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "otel_spans_dropped_total",
+			Help:      "Total spans lost before reaching the collector, from a full export queue or a failed export",
+		}, []string{"service", "reason"}),
+	}
+}
+
+// labelNamePattern is the Prometheus label name syntax; names starting
+// with __ are reserved.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are label names the metrics already vary by, including
+// those of the Go runtime collector and the le and quantile labels of
+// histograms and summaries. A constant label of the same name makes
+// registration fail.
+var reservedLabels = map[string]bool{
+	"check": true, "client": true, "code": true, "kind": true, "le": true,
+	"method": true, "pool": true, "priority": true, "quantile": true,
+	"queue": true, "reason": true, "result": true, "route": true,
+	"service": true, "source": true, "state": true, "status": true,
+	"subject": true, "tenant": true, "type": true, "version": true,
+	"window": true,
+}
+
+// Config is what sets the metrics of one install apart from another
+// scraped into the same Prometheus: a prefix ahead of every metric name and
+// constant labels such as cluster, environment or region.
+type Config struct {
+	Prefix string
+	Labels prometheus.Labels
+}
+
+// ParseConfig parses a metric name prefix and comma-separated name=value
+// constant labels, as set in METRICS_PREFIX and METRICS_CONST_LABELS.
+func ParseConfig(prefix, labels string) (Config, error) {
+	cfg := Config{Labels: prometheus.Labels{}}
+	if prefix != "" {
+		if !labelNamePattern.MatchString(prefix) {
+			return Config{}, fmt.Errorf("invalid metric prefix %q", prefix)
+		}
+		cfg.Prefix = strings.TrimSuffix(prefix, "_") + "_"
+	}
+	for _, pair := range strings.Split(labels, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return Config{}, fmt.Errorf("invalid constant label %q: want name=value", pair)
+		}
+		if reservedLabels[name] {
+			return Config{}, fmt.Errorf("constant label %q is already a label of the metrics", name)
+		}
+		if _, dup := cfg.Labels[name]; dup {
+			return Config{}, fmt.Errorf("constant label %q set twice", name)
+		}
+		value = strings.TrimSpace(value)
+		if !utf8.ValidString(value) {
+			return Config{}, fmt.Errorf("constant label %q: value is not valid UTF-8", name)
+		}
+		cfg.Labels[name] = value
+	}
+	return cfg, nil
+}
+
+// Name returns the exported name of the metric name, which is given without
+// the namespace, e.g. "http_requests_total".
+func (c Config) Name(name string) string {
+	return c.Prefix + Namespace + "_" + name
+}
+
+// Selector returns PromQL matchers for the constant labels, e.g.
+// `cluster="eu1", environment="prod"`, or "" without any.
+func (c Config) Selector() string {
+	names := make([]string, 0, len(c.Labels))
+	for name := range c.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matchers := make([]string, len(names))
+	for i, name := range names {
+		matchers[i] = fmt.Sprintf("%s=%q", name, c.Labels[name])
+	}
+	return strings.Join(matchers, ", ")
+}
+
+// NewRegistry returns a registry with the Go runtime and process collectors
+// the default registry would have carried, and the registerer to create
+// metrics on. Everything registered through it, the runtime collectors
+// included, gets cfg's prefix and constant labels.
+func NewRegistry(cfg Config) (*prometheus.Registry, prometheus.Registerer) {
+	reg := prometheus.NewRegistry()
+	var wrapped prometheus.Registerer = reg
+	if len(cfg.Labels) > 0 {
+		wrapped = prometheus.WrapRegistererWith(cfg.Labels, wrapped)
+	}
+	if cfg.Prefix != "" {
+		wrapped = prometheus.WrapRegistererWithPrefix(cfg.Prefix, wrapped)
+	}
+	wrapped.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg, wrapped
+}
+
+// Handler serves reg in the Prometheus exposition format, instrumented like
+// promhttp.Handler.
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.InstrumentMetricHandler(reg, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// descNames registers collectors on a fresh registry and keeps the names
+// and variable label names of the metrics they describe.
+type descNames struct {
+	*prometheus.Registry
+	names  []string
+	labels []string
+}
+
+var (
+	fqNamePattern         = regexp.MustCompile(`fqName: "([^"]*)"`)
+	variableLabelsPattern = regexp.MustCompile(`variableLabels: \{([^}]*)\}`)
+)
+
+func (d *descNames) Register(c prometheus.Collector) error {
+	if err := d.Registry.Register(c); err != nil {
+		return err
+	}
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	for desc := range ch {
+		if m := fqNamePattern.FindStringSubmatch(desc.String()); m != nil {
+			d.names = append(d.names, m[1])
+		}
+		if m := variableLabelsPattern.FindStringSubmatch(desc.String()); m != nil && m[1] != "" {
+			d.labels = append(d.labels, strings.Split(m[1], ",")...)
+		}
+	}
+	return nil
+}
+
+func (d *descNames) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := d.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestMetricNamesAreNamespaced(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func(prometheus.Registerer)
+	}{
+		{"api", func(reg prometheus.Registerer) { NewAPI(reg) }},
+		{"worker", func(reg prometheus.Registerer) { NewWorker(reg, nil) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := &descNames{Registry: prometheus.NewRegistry()}
+			tt.new(reg)
+			if len(reg.names) == 0 {
+				t.Fatal("no metrics registered")
+			}
+			for _, name := range reg.names {
+				if !strings.HasPrefix(name, Namespace+"_") {
+					t.Errorf("metric %s lacks the %s_ namespace", name, Namespace)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConfigRejectsMetricLabels(t *testing.T) {
+	reg := &descNames{Registry: prometheus.NewRegistry()}
+	reg.MustRegister(collectors.NewGoCollector())
+	NewAPI(reg)
+	NewWorker(prometheus.WrapRegistererWithPrefix("worker_", reg), nil)
+	// Histograms and summaries add these when collected.
+	labels := append(reg.labels, "le", "quantile")
+	for _, name := range labels {
+		if _, err := ParseConfig("", name+"=x"); err == nil {
+			t.Errorf("ParseConfig accepts constant label %q, which a metric already uses", name)
+		}
+	}
+}
+
+func TestRegistriesAreIsolated(t *testing.T) {
+	// Each binary, and each test, gets its own registry, so building the
+	// metrics again must not collide with an earlier set.
+	for i := 0; i < 2; i++ {
+		NewAPI(prometheus.NewRegistry())
+		NewWorker(prometheus.NewRegistry(), nil)
+	}
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	NewWorker(reg, nil)
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			t.Fatalf("second NewWorker on one registry: recovered %v, want AlreadyRegisteredError", err)
+		}
+	}()
+	NewWorker(reg, nil)
+}
+
+func TestNewRegistryAppliesConfig(t *testing.T) {
+	cfg, err := ParseConfig("eu", "cluster=eu1, environment=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, wrapped := NewRegistry(cfg)
+	m := NewAPI(wrapped)
+	m.HTTPRequests.WithLabelValues("codigo-api", "/v1/jobs", "POST", "202", "acme", "report").Inc()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cfg.Name("http_requests_total")
+	for _, mf := range families {
+		if !strings.HasPrefix(mf.GetName(), "eu_") {
+			t.Errorf("metric %s lacks the eu_ prefix", mf.GetName())
+		}
+		if mf.GetName() != want {
+			continue
+		}
+		labels := map[string]string{}
+		for _, l := range mf.GetMetric()[0].GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["cluster"] != "eu1" || labels["environment"] != "prod" || labels["service"] != "codigo-api" {
+			t.Errorf("%s labels = %v, want cluster, environment and service", want, labels)
+		}
+		return
+	}
+	t.Errorf("%s not gathered", want)
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		prefix, labels string
+		wantErr        bool
+	}{
+		{"", "", false},
+		{"eu_", "cluster=eu1", false},
+		{"eu-1", "", true},
+		{"", "cluster", true},
+		{"", "__name__=x", true},
+		{"", "service=api", true},
+		{"", "route=/v1/jobs", true},
+		{"", "cluster=a,cluster=b", true},
+	}
+	for _, tt := range tests {
+		_, err := ParseConfig(tt.prefix, tt.labels)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseConfig(%q, %q) error = %v, wantErr %v", tt.prefix, tt.labels, err, tt.wantErr)
+		}
+	}
+}
+
+func FuzzParseConfig(f *testing.F) {
+	f.Add("eu_", "cluster=eu1, environment=prod")
+	f.Add("", "route=x")
+	f.Add("", "region=\xff")
+	f.Add("_", "a==b,,")
+	f.Fuzz(func(t *testing.T, prefix, labels string) {
+		cfg, err := ParseConfig(prefix, labels)
+		if err != nil {
+			return
+		}
+		// A config ParseConfig accepts must not fail the binaries at startup.
+		_, api := NewRegistry(cfg)
+		NewAPI(api)
+		_, worker := NewRegistry(cfg)
+		NewWorker(worker, nil)
+	})
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultQueueWaitBuckets span sub-second pickup up to jobs stuck for ten
+// minutes behind a backlog.
+var DefaultQueueWaitBuckets = []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 600}
+
+// Worker are the metrics of the worker binary.
+type Worker struct {
+	Common
+
+	JobsProcessed *prometheus.CounterVec
+	JobsExpired   *prometheus.CounterVec
+	JobLatency    *prometheus.HistogramVec
+	JobQueueWait  *prometheus.HistogramVec
+	JobExports    *prometheus.CounterVec
+	JobsInFlight  *prometheus.GaugeVec
+
+	NATSMessagesReceived *prometheus.CounterVec
+	TenantJobsDispatched *prometheus.CounterVec
+	TenantJobsRefused    *prometheus.CounterVec
+	TenantQueueDepth     *prometheus.GaugeVec
+	AdaptiveConcurrency  *prometheus.GaugeVec
+	WatchdogAlerts       *prometheus.CounterVec
+}
+
+// NewWorker creates the worker metrics and registers them on reg.
+// queueWaitBuckets are the bounds of job_queue_wait_seconds; nil uses
+// DefaultQueueWaitBuckets.
+func NewWorker(reg prometheus.Registerer, queueWaitBuckets []float64) *Worker {
+	if queueWaitBuckets == nil {
+		queueWaitBuckets = DefaultQueueWaitBuckets
+	}
+	f := promauto.With(reg)
+	return &Worker{
+		Common: newCommon(f),
+		JobsProcessed: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "jobs_processed_total",
+			Help:      "Total jobs processed",
+		}, []string{"service", "result", "tenant", "type"}),
+		JobsExpired: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "jobs_expired_total",
+			Help:      "Jobs skipped because their deadline passed before a worker started them",
+		}, []string{"service", "tenant", "type"}),
+		JobLatency: f.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "job_processing_duration_seconds",
+			Help:      "Job processing duration",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"service"}),
+		JobQueueWait: f.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "job_queue_wait_seconds",
+			Help:      "Time between the API publishing a job and a worker starting it",
+			Buckets:   queueWaitBuckets,
+		}, []string{"service", "priority", "type"}),
+		JobExports: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "job_exports_total",
+			Help:      "Job completion records published to WORKER_EXPORT_SUBJECT",
+		}, []string{"service", "result"}),
+		JobsInFlight: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "jobs_in_flight",
+			Help:      "Jobs currently being processed",
+		}, []string{"service", "type"}),
+		NATSMessagesReceived: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "nats_messages_received_total",
+			Help:      "Total NATS messages received",
+		}, []string{"service", "subject"}),
+		TenantJobsDispatched: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "worker_tenant_jobs_dispatched_total",
+			Help:      "Total jobs handed to worker goroutines per queue and tenant",
+		}, []string{"service", "queue", "tenant"}),
+		TenantJobsRefused: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "worker_tenant_jobs_refused_total",
+			Help:      "Total jobs the worker refused back to the API because their tenant's buffer was full, per queue and tenant",
+		}, []string{"service", "queue", "tenant"}),
+		TenantQueueDepth: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "worker_tenant_queue_depth",
+			Help:      "Jobs buffered in the worker waiting for a free slot per queue and tenant",
+		}, []string{"service", "queue", "tenant"}),
+		AdaptiveConcurrency: f.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "worker_adaptive_concurrency",
+			Help:      "Goroutines per queue currently allowed to take jobs, lowered while the database pool is saturated",
+		}, []string{"service", "queue"}),
+		WatchdogAlerts: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "watchdog_alerts_total",
+			Help:      "Leak watchdog findings by check (goroutines, heap, stuck_job)",
+		}, []string{"service", "check"}),
+	}
+}
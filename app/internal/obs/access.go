@@ -0,0 +1,79 @@
+package obs
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// ProtectMetrics wraps the /metrics handler with the optional basic auth
+// (METRICS_BASIC_AUTH_USER / METRICS_BASIC_AUTH_PASSWORD) and source
+// allow-list (METRICS_ALLOWED_CIDRS) checks. With neither configured h is
+// returned unchanged.
+func ProtectMetrics(h http.Handler) (http.Handler, error) {
+	user := os.Getenv("METRICS_BASIC_AUTH_USER")
+	pass := os.Getenv("METRICS_BASIC_AUTH_PASSWORD")
+	if (user == "") != (pass == "") {
+		return nil, fmt.Errorf("METRICS_BASIC_AUTH_USER and METRICS_BASIC_AUTH_PASSWORD must be set together")
+	}
+
+	var allowed []netip.Prefix
+	for _, c := range strings.Split(os.Getenv("METRICS_ALLOWED_CIDRS"), ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid METRICS_ALLOWED_CIDRS entry %q: %w", c, err)
+		}
+		allowed = append(allowed, p.Masked())
+	}
+
+	if user == "" && len(allowed) == 0 {
+		return h, nil
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the connection's address counts; forwarding headers are
+		// client-controlled.
+		if len(allowed) > 0 && !addrAllowed(r.RemoteAddr, allowed) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if user != "" {
+			u, p, ok := r.BasicAuth()
+			userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(p), []byte(pass)) == 1
+			if !ok || !userOK || !passOK {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	}), nil
+}
+
+// addrAllowed reports whether the host part of remoteAddr falls within one
+// of the prefixes.
+func addrAllowed(remoteAddr string, allowed []netip.Prefix) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range allowed {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
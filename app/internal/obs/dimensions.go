@@ -0,0 +1,177 @@
+package obs
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"codigo/internal/config"
+)
+
+const (
+	// otherLabelValue replaces label values outside the top K.
+	otherLabelValue = "other"
+
+	// maxTrackedLabelValues bounds the per-window counting map; values
+	// beyond it are counted as other.
+	maxTrackedLabelValues = 10000
+)
+
+// Dimensions adds capped tenant and job type labels to request and job
+// metrics. It is nil, and the labels stay empty, unless
+// METRICS_TENANT_DIMENSIONS=true.
+type Dimensions struct {
+	tenants *TopKLabel
+	types   *TopKLabel
+}
+
+// LoadDimensions reads METRICS_TENANT_DIMENSIONS, METRICS_DIMENSION_TOP_K
+// and METRICS_DIMENSION_WINDOW.
+func LoadDimensions() *Dimensions {
+	if config.String("METRICS_TENANT_DIMENSIONS", "false") != "true" {
+		return nil
+	}
+	k := config.Int("METRICS_DIMENSION_TOP_K", 20)
+	window := config.Duration("METRICS_DIMENSION_WINDOW", time.Hour)
+	return &Dimensions{
+		tenants: NewTopKLabel(k, window),
+		types:   NewTopKLabel(k, window),
+	}
+}
+
+// Labels returns the tenant and type label values to record.
+func (d *Dimensions) Labels(tenant, jobType string) (string, string) {
+	if d == nil {
+		return "", ""
+	}
+	return d.tenants.Value(tenant), d.types.Value(jobType)
+}
+
+// Tenant returns the tenant label value to record, for metrics without a
+// type label.
+func (d *Dimensions) Tenant(tenant string) string {
+	if d == nil {
+		return ""
+	}
+	return d.tenants.Value(tenant)
+}
+
+// DeleteEvicted deletes, at every rotation, the series of vecs whose
+// "tenant" or "type" label holds a value that just lost its own series, so
+// each window's newcomers replace the last window's instead of adding to
+// them.
+func (d *Dimensions) DeleteEvicted(vecs ...*prometheus.MetricVec) {
+	if d == nil {
+		return
+	}
+	d.tenants.OnEvict(DeleteLabel("tenant", vecs...))
+	d.types.OnEvict(DeleteLabel("type", vecs...))
+}
+
+// DeleteLabel returns an eviction callback that deletes the series of vecs
+// whose label is the evicted value.
+func DeleteLabel(label string, vecs ...*prometheus.MetricVec) func(value string) {
+	return func(value string) {
+		for _, vec := range vecs {
+			vec.DeletePartialMatch(prometheus.Labels{label: value})
+		}
+	}
+}
+
+// TopKLabel keeps a label's cardinality bounded: only the k values seen most
+// often in the previous window keep their own series and the rest are
+// recorded as "other". Before the first window closes, the first k distinct
+// values are admitted.
+type TopKLabel struct {
+	k int
+
+	mu      sync.Mutex
+	allowed map[string]bool
+	counts  map[string]int
+	ranked  bool
+	evict   []func(value string)
+}
+
+// NewTopKLabel ranks values over windows of the given length.
+func NewTopKLabel(k int, window time.Duration) *TopKLabel {
+	l := &TopKLabel{
+		k:       k,
+		allowed: make(map[string]bool),
+		counts:  make(map[string]int),
+	}
+	go func() {
+		for range time.Tick(window) {
+			l.rotate()
+		}
+	}()
+	return l
+}
+
+// Value counts v and returns the label value to record for it.
+func (l *TopKLabel) Value(v string) string {
+	// Jobs without a tenant or type are published under the default
+	// subject token; label them the same way.
+	if v == "" {
+		v = "default"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.counts[v]; ok || len(l.counts) < maxTrackedLabelValues {
+		l.counts[v]++
+	}
+	if l.allowed[v] {
+		return v
+	}
+	if !l.ranked && len(l.allowed) < l.k {
+		l.allowed[v] = true
+		return v
+	}
+	return otherLabelValue
+}
+
+// OnEvict registers f to be called with every value that loses its own
+// series at a rotation, so the metrics labelled with it can delete them.
+// Gauges moved with Inc and Dec should be changed through the handle
+// WithLabelValues returned, which a deletion detaches instead of resetting.
+func (l *TopKLabel) OnEvict(f func(value string)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.evict = append(l.evict, f)
+}
+
+// rotate admits the k most frequent values of the closing window and hands
+// the values it drops to the eviction callbacks.
+func (l *TopKLabel) rotate() {
+	l.mu.Lock()
+	values := make([]string, 0, len(l.counts))
+	for v := range l.counts {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return l.counts[values[i]] > l.counts[values[j]] })
+	if len(values) > l.k {
+		values = values[:l.k]
+	}
+	previous := l.allowed
+	l.allowed = make(map[string]bool, len(values))
+	for _, v := range values {
+		l.allowed[v] = true
+	}
+	l.counts = make(map[string]int)
+	l.ranked = true
+	var evicted []string
+	for v := range previous {
+		if !l.allowed[v] {
+			evicted = append(evicted, v)
+		}
+	}
+	callbacks := l.evict
+	l.mu.Unlock()
+
+	for _, v := range evicted {
+		for _, f := range callbacks {
+			f(v)
+		}
+	}
+}
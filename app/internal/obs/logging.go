@@ -0,0 +1,34 @@
+package obs
+
+import (
+	"fmt"
+
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"codigo/internal/config"
+)
+
+// NewLogger is the structured logger every component logs through. Every
+// entry carries the binary's region when one is set.
+func NewLogger(lc fx.Lifecycle, cfg config.App) (*zap.Logger, error) {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	if cfg.Region != "" {
+		logger = logger.With(zap.String("region", cfg.Region))
+	}
+	lc.Append(fx.StopHook(func() { logger.Sync() }))
+	return logger, nil
+}
+
+// FxLogger logs the container's own events through logger, at debug level
+// except for errors.
+func FxLogger(logger *zap.Logger) fxevent.Logger {
+	l := &fxevent.ZapLogger{Logger: logger}
+	l.UseLogLevel(zapcore.DebugLevel)
+	return l
+}
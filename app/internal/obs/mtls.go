@@ -0,0 +1,57 @@
+package obs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// MetricsTLSConfig builds a server TLS config that only accepts clients
+// presenting a certificate signed by METRICS_TLS_CLIENT_CA_FILE.
+// It returns nil when metrics mTLS is not configured.
+func MetricsTLSConfig() (*tls.Config, error) {
+	certFile := os.Getenv("METRICS_TLS_CERT_FILE")
+	keyFile := os.Getenv("METRICS_TLS_KEY_FILE")
+	caFile := os.Getenv("METRICS_TLS_CLIENT_CA_FILE")
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, fmt.Errorf("METRICS_TLS_CERT_FILE, METRICS_TLS_KEY_FILE and METRICS_TLS_CLIENT_CA_FILE must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metrics TLS key pair: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// ServeMTLS serves h on l, requiring verified client certificates.
+func ServeMTLS(l net.Listener, cfg *tls.Config, h http.Handler) error {
+	srv := &http.Server{
+		Handler:           h,
+		TLSConfig:         cfg,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return srv.ServeTLS(l, "", "")
+}
@@ -0,0 +1,67 @@
+package obs
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+	"codigo/internal/metrics"
+)
+
+// ServeMetrics exposes registry at /metrics. With mTLS configured, metrics
+// get their own listener on METRICS_TLS_ADDR so only clients holding a
+// certificate from the configured CA can scrape them. METRICS_ADDR moves
+// them to a separate plain listener instead. Otherwise the handler is passed
+// to mount, which serves it next to the binary's other routes.
+func ServeMetrics(lc fx.Lifecycle, logger *zap.Logger, registry *prometheus.Registry, mount func(pattern string, h http.Handler)) error {
+	metricsTLS, err := MetricsTLSConfig()
+	if err != nil {
+		return fmt.Errorf("invalid metrics TLS configuration: %w", err)
+	}
+	metricsHandler, err := ProtectMetrics(metrics.Handler(registry))
+	if err != nil {
+		return fmt.Errorf("invalid metrics access configuration: %w", err)
+	}
+
+	switch metricsAddr := os.Getenv("METRICS_ADDR"); {
+	case metricsTLS != nil:
+		metricsAddr = config.String("METRICS_TLS_ADDR", ":9443")
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metricsHandler)
+		l, err := config.Listen("metrics", metricsAddr)
+		if err != nil {
+			return fmt.Errorf("metrics mTLS listener failed: %w", err)
+		}
+		lc.Append(fx.StartHook(func() {
+			go func() {
+				logger.Info("metrics mTLS server starting", zap.String("address", l.Addr().String()))
+				if err := ServeMTLS(l, metricsTLS, metricsMux); err != nil {
+					logger.Fatal("metrics mTLS server failed", zap.Error(err))
+				}
+			}()
+		}))
+	case metricsAddr != "":
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metricsHandler)
+		l, err := config.Listen("metrics", metricsAddr)
+		if err != nil {
+			return fmt.Errorf("metrics listener failed: %w", err)
+		}
+		lc.Append(fx.StartHook(func() {
+			go func() {
+				logger.Info("metrics server starting", zap.String("address", l.Addr().String()))
+				if err := http.Serve(l, metricsMux); err != nil {
+					logger.Fatal("metrics server failed", zap.Error(err))
+				}
+			}()
+		}))
+	default:
+		mount("/metrics", metricsHandler)
+	}
+	return nil
+}
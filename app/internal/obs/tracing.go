@@ -0,0 +1,201 @@
+// Package obs holds the logging, tracing, metrics exposure and metric
+// labelling both binaries share.
+package obs
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"codigo/internal/config"
+	"codigo/internal/metrics"
+)
+
+// InitTracing sets the global tracer provider and propagator, exporting to
+// OTEL_EXPORTER_OTLP_ENDPOINT, and returns the function that flushes and
+// stops them. Without an endpoint tracing stays disabled. Export results
+// are counted in m.
+func InitTracing(ctx context.Context, serviceName, region string, m *metrics.Common) func() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		log.Printf("otel disabled (OTEL_EXPORTER_OTLP_ENDPOINT not set)")
+		return func() {}
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpointURL(endpoint),
+		otlptracehttp.WithTimeout(2 * time.Second),
+	}
+	// Bursty job traffic produces large batches; compress them unless
+	// OTEL_EXPORTER_OTLP_COMPRESSION says otherwise.
+	if config.String("OTEL_EXPORTER_OTLP_COMPRESSION", "gzip") == "gzip" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	exp, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		log.Printf("otel exporter init failed: %v", err)
+		return func() {}
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceName(serviceName)}
+	if region != "" {
+		attrs = append(attrs, semconv.CloudRegion(region))
+	}
+	res, _ := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, attrs...),
+	)
+
+	// The batch processor reads OTEL_BSP_SCHEDULE_DELAY and
+	// OTEL_BSP_EXPORT_TIMEOUT from the environment. Its queue is sized by
+	// the guard in front of it, which drops and counts what doesn't fit.
+	guard := &queueGuard{
+		max:     int64(config.Int("OTEL_BSP_MAX_QUEUE_SIZE", 2048) + config.Int("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", 512)),
+		service: serviceName,
+		metrics: m,
+	}
+	guard.SpanProcessor = sdktrace.NewBatchSpanProcessor(
+		&countingExporter{SpanExporter: exp, guard: guard, service: serviceName, metrics: m},
+		sdktrace.WithMaxQueueSize(int(guard.max)),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(debugSampler{base: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio()))}),
+		sdktrace.WithSpanProcessor(guard),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	// Set global propagator for trace context propagation
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return func() {
+		_ = tp.Shutdown(context.Background())
+	}
+}
+
+// countingExporter records how many spans reach the collector and how many
+// are lost to failed exports, and releases them from the guard's count.
+type countingExporter struct {
+	sdktrace.SpanExporter
+	guard   *queueGuard
+	service string
+	metrics *metrics.Common
+}
+
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.guard.queued.Add(-int64(len(spans)))
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		e.metrics.OTelSpansDropped.WithLabelValues(e.service, "export_failed").Add(float64(len(spans)))
+		return err
+	}
+	e.metrics.OTelSpansExported.WithLabelValues(e.service).Add(float64(len(spans)))
+	return nil
+}
+
+// queueGuard sits in front of the batch span processor and counts the
+// sampled spans it holds, from their end until their batch is exported. A
+// span that ends with max spans held is dropped and counted here, since
+// the batch processor drops spans from a full queue without telling
+// anyone. The batch processor's queue is sized to max, so it never drops
+// one itself; max includes a batch's worth because spans being batched have
+// left that queue but not yet reached the exporter.
+type queueGuard struct {
+	sdktrace.SpanProcessor
+	max     int64
+	queued  atomic.Int64
+	service string
+	metrics *metrics.Common
+}
+
+func (g *queueGuard) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	g.metrics.OTelSpansEnded.WithLabelValues(g.service).Inc()
+	if g.queued.Add(1) > g.max {
+		g.queued.Add(-1)
+		g.metrics.OTelSpansDropped.WithLabelValues(g.service, "queue_full").Inc()
+		return
+	}
+	g.SpanProcessor.OnEnd(s)
+}
+
+// debugBaggageKey marks a request whose whole trace, including downstream job
+// processing, must be sampled regardless of the sampling ratio.
+const debugBaggageKey = "codigo.debug_trace"
+
+// debugSampler always samples spans carrying the debug baggage member and
+// defers to base for everything else.
+type debugSampler struct {
+	base sdktrace.Sampler
+}
+
+func (s debugSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if DebugTraceEnabled(p.ParentContext) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s debugSampler) Description() string {
+	return "DebugOverride{" + s.base.Description() + "}"
+}
+
+// sampleRatio reads TRACE_SAMPLE_RATIO (0..1, default 1).
+func sampleRatio() float64 {
+	ratio, err := strconv.ParseFloat(os.Getenv("TRACE_SAMPLE_RATIO"), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return 1
+	}
+	return ratio
+}
+
+// WithDebugTrace sets or clears the debug baggage member. Clearing matters as
+// much as setting: clients must not be able to force sampling by sending
+// the baggage header themselves.
+func WithDebugTrace(ctx context.Context, enabled bool) context.Context {
+	bag := baggage.FromContext(ctx).DeleteMember(debugBaggageKey)
+	if enabled {
+		if m, err := baggage.NewMember(debugBaggageKey, "1"); err == nil {
+			bag, _ = bag.SetMember(m)
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// DebugTraceEnabled reports whether ctx carries the debug baggage member.
+func DebugTraceEnabled(ctx context.Context) bool {
+	return baggage.FromContext(ctx).Member(debugBaggageKey).Value() == "1"
+}
+
+// DebugGuardPropagator keeps the debug decision already made for the request
+// when extracting incoming headers, which would otherwise replace it with
+// whatever baggage the client sent.
+type DebugGuardPropagator struct {
+	propagation.TextMapPropagator
+}
+
+func (p DebugGuardPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return WithDebugTrace(p.TextMapPropagator.Extract(ctx, carrier), DebugTraceEnabled(ctx))
+}
@@ -0,0 +1,60 @@
+// Package queue is the NATS side of job delivery: connecting, the subjects
+// jobs are published on, the headers the API stamps on them and the
+// per-tenant payload encryption.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+)
+
+// Connect connects to NATS_URL, which may list several comma-separated
+// servers (e.g. the local cluster first, then remote gateways). The client
+// keeps reconnecting forever, so a cluster failover never needs a restart.
+func Connect(logger *zap.Logger, vault *config.Vault) (*nats.Conn, error) {
+	url := config.String("NATS_URL", "nats://127.0.0.1:4222")
+	opts := []nats.Option{
+		nats.Timeout(2 * time.Second),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(config.Duration("NATS_RECONNECT_WAIT", 2*time.Second)),
+		nats.ReconnectJitter(500*time.Millisecond, time.Second),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			logger.Warn("nats disconnected", zap.Error(err))
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Info("nats reconnected",
+				zap.String("server", nc.ConnectedUrlRedacted()),
+				zap.String("cluster", nc.ConnectedClusterName()))
+		}),
+	}
+	// VAULT_NATS_PATH holds either jwt and seed, a token, or user and password
+	if path := os.Getenv("VAULT_NATS_PATH"); vault != nil && path != "" {
+		secret, err := vault.Read(context.Background(), path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NATS credentials from Vault: %w", err)
+		}
+		switch {
+		case secret.Field("jwt") != "":
+			opts = append(opts, nats.UserJWTAndSeed(secret.Field("jwt"), secret.Field("seed")))
+		case secret.Field("token") != "":
+			opts = append(opts, nats.Token(secret.Field("token")))
+		default:
+			opts = append(opts, nats.UserInfo(secret.Field("user"), secret.Field("password")))
+		}
+	}
+	nc, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("nats connected",
+		zap.String("server", nc.ConnectedUrlRedacted()),
+		zap.String("cluster", nc.ConnectedClusterName()))
+	return nc, nil
+}
@@ -0,0 +1,15 @@
+package queue
+
+// Jobs are published as requests and the worker that receives one answers
+// whether it took the job. Core NATS discards a message nobody takes and
+// never redelivers, so the answer is the only way the API learns that a job
+// was refused by a full worker, or published while no worker subscribed,
+// instead of leaving it queued with nothing to run it.
+const (
+	// DispatchAccepted means the worker buffered the job and will run it.
+	DispatchAccepted = "accepted"
+
+	// DispatchBusy means the worker refused the job because its tenant's
+	// buffer is full. Another replica may still take it.
+	DispatchBusy = "busy"
+)
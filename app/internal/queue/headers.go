@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// RegionHeader carries the REGION of the publisher on every message so
+	// consumers in another cluster can tell where a job came from.
+	RegionHeader = "Codigo-Region"
+
+	// PublishedAtHeader carries the RFC 3339 time a job was published so
+	// workers can measure how long it waited in the queue.
+	PublishedAtHeader = "Codigo-Published-At"
+
+	// DeadlineHeader carries the job's deadline to the workers as RFC 3339,
+	// so jobs nobody is waiting for any more are expired instead of run.
+	DeadlineHeader = "Codigo-Deadline"
+
+	// PriorityHeader carries the job's priority; jobs without one count as
+	// normal.
+	PriorityHeader = "Codigo-Priority"
+
+	// JobIDHeader carries the job's ID in clear text, so a worker that
+	// can't decrypt the payload can still record the job as failed. IDs are
+	// made by the API and reveal nothing the other headers don't.
+	JobIDHeader = "Codigo-Job-Id"
+)
+
+// Deadline returns the deadline carried by m. Jobs without one, or with one
+// that can't be parsed, never expire.
+func Deadline(m *nats.Msg) (time.Time, bool) {
+	deadline, err := time.Parse(time.RFC3339Nano, m.Header.Get(DeadlineHeader))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return deadline, true
+}
+
+// HeaderCarrier adapts NATS headers to OpenTelemetry propagation
+type HeaderCarrier nats.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	vals := nats.Header(c).Values(key)
+	if len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// KeyIDHeader names the data key a job payload was encrypted with;
+	// messages without it are plaintext.
+	KeyIDHeader = "Codigo-Key-Id"
+
+	// CipherHeader names the payload cipher, which is always Cipher.
+	CipherHeader = "Codigo-Cipher"
+
+	Cipher = "aes-256-gcm"
+)
+
+// Keyring holds the data keys used for per-tenant payload encryption.
+// Keys come from PAYLOAD_KEYS (comma-separated id=base64 pairs, as
+// unwrapped from KMS by the deployment) and TENANT_PAYLOAD_KEYS maps tenants
+// to the key id new messages are encrypted with. Old keys stay in
+// PAYLOAD_KEYS until no message encrypted with them can still be queued.
+type Keyring struct {
+	keys    map[string]cipher.AEAD
+	tenants map[string]string
+}
+
+// LoadKeyring reads PAYLOAD_KEYS and TENANT_PAYLOAD_KEYS.
+func LoadKeyring() (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string]cipher.AEAD), tenants: make(map[string]string)}
+	for _, entry := range strings.Split(os.Getenv("PAYLOAD_KEYS"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		id, encoded, _ := strings.Cut(entry, "=")
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("PAYLOAD_KEYS entry %q must be a base64 32-byte key", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[id] = aead
+	}
+	for _, entry := range strings.Split(os.Getenv("TENANT_PAYLOAD_KEYS"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		tenant, id, _ := strings.Cut(entry, "=")
+		if _, ok := kr.keys[id]; !ok {
+			return nil, fmt.Errorf("TENANT_PAYLOAD_KEYS: tenant %q uses unknown key %q", tenant, id)
+		}
+		kr.tenants[tenant] = id
+	}
+	return kr, nil
+}
+
+// Seal encrypts data for tenant, binding it to the tenant so a message can't
+// be replayed under another tenant's subject. It returns the key id to send
+// in KeyIDHeader, or "" and data unchanged for tenants without a key.
+func (kr *Keyring) Seal(tenant string, data []byte) (string, []byte, error) {
+	id, ok := kr.tenants[tenant]
+	if !ok {
+		return "", data, nil
+	}
+	aead := kr.keys[id]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return id, aead.Seal(nonce, nonce, data, []byte(tenant)), nil
+}
+
+// Open returns the plaintext payload of m, decrypting it when the API
+// encrypted it for tenant.
+func (kr *Keyring) Open(m *nats.Msg, tenant string) ([]byte, error) {
+	id := m.Header.Get(KeyIDHeader)
+	if id == "" {
+		return m.Data, nil
+	}
+	if c := m.Header.Get(CipherHeader); c != Cipher {
+		return nil, fmt.Errorf("unsupported payload cipher %q", c)
+	}
+	aead, ok := kr.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload key %q", id)
+	}
+	if len(m.Data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted payload too short")
+	}
+	nonce, sealed := m.Data[:aead.NonceSize()], m.Data[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(tenant))
+}
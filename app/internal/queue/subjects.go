@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultToken is used for jobs created without a tenant or type.
+const DefaultToken = "default"
+
+var subjectTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidToken reports whether s can be used as a single NATS subject token
+// without introducing wildcards or extra levels.
+func ValidToken(s string) bool {
+	return subjectTokenPattern.MatchString(s)
+}
+
+// JobSubject is the subject a job is published on: jobs.{tenant}.{type}.
+// Worker pools subscribe to a subset of these to isolate noisy tenants.
+func JobSubject(tenant, jobType string) string {
+	return "jobs." + tenant + "." + jobType
+}
+
+// SubjectLabel is the metric label for subject: its first token, "jobs" for
+// every job. The tenant and type tokens come from client headers, so they
+// only reach metrics through the capped obs.Dimensions labels.
+func SubjectLabel(subject string) string {
+	root, _, _ := strings.Cut(subject, ".")
+	return root
+}
+
+// TenantType extracts tenant and type from a jobs.{tenant}.{type} subject,
+// falling back to DefaultToken for anything else.
+func TenantType(subject string) (tenant, jobType string) {
+	parts := strings.Split(subject, ".")
+	if len(parts) != 3 || parts[0] != "jobs" {
+		return DefaultToken, DefaultToken
+	}
+	return parts[1], parts[2]
+}
+
+// SubjectsOverlap reports whether some subject matches both patterns, which
+// may use the * and > wildcards. Subscriptions in different queue groups on
+// overlapping patterns each receive such a subject.
+func SubjectsOverlap(a, b string) bool {
+	at, bt := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		switch {
+		case at[i] == ">" || bt[i] == ">":
+			return true
+		case at[i] == "*" || bt[i] == "*" || at[i] == bt[i]:
+		default:
+			return false
+		}
+	}
+	return len(at) == len(bt)
+}
@@ -0,0 +1,34 @@
+package queue
+
+import "testing"
+
+func FuzzTenantType(f *testing.F) {
+	f.Add("jobs.acme.report")
+	f.Add("jobs..")
+	f.Add("jobs.acme.report.extra")
+	f.Fuzz(func(t *testing.T, subject string) {
+		tenant, jobType := TenantType(subject)
+		if subject != JobSubject(tenant, jobType) && (tenant != DefaultToken || jobType != DefaultToken) {
+			t.Errorf("TenantType(%q) = %q, %q, which is neither the subject's tokens nor the default", subject, tenant, jobType)
+		}
+		if ValidToken(tenant) && ValidToken(jobType) {
+			if gotTenant, gotType := TenantType(JobSubject(tenant, jobType)); gotTenant != tenant || gotType != jobType {
+				t.Errorf("TenantType(JobSubject(%q, %q)) = %q, %q", tenant, jobType, gotTenant, gotType)
+			}
+		}
+	})
+}
+
+func FuzzSubjectsOverlap(f *testing.F) {
+	f.Add("jobs.acme.*", "jobs.*.report")
+	f.Add("jobs.>", "jobs")
+	f.Add("jobs.acme.report", "jobs.globex.report")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if SubjectsOverlap(a, b) != SubjectsOverlap(b, a) {
+			t.Errorf("SubjectsOverlap(%q, %q) is not symmetric", a, b)
+		}
+		if !SubjectsOverlap(a, a) {
+			t.Errorf("SubjectsOverlap(%q, %q) = false for the same pattern", a, a)
+		}
+	})
+}
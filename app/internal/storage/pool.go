@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+)
+
+// credentials are the Postgres user and password new connections use.
+type credentials struct {
+	user     string
+	password string
+}
+
+// Open connects to the database named by POSTGRES_HOST, POSTGRES_PORT and
+// POSTGRES_DB and returns one pool per tune function, each of which adjusts
+// a copy of the shared config. Without tune functions a single pool uses the
+// config unchanged. The pools share credentials: POSTGRES_USER and
+// POSTGRES_PASSWORD, or the secret at VAULT_POSTGRES_PATH, which is watched
+// so every pool reconnects when the credentials rotate.
+func Open(ctx context.Context, logger *zap.Logger, vault *config.Vault, tune ...func(*pgxpool.Config)) ([]*pgxpool.Pool, error) {
+	host := config.String("POSTGRES_HOST", "localhost")
+	port := config.String("POSTGRES_PORT", "5432")
+	db := config.String("POSTGRES_DB", "codigo")
+	user := config.String("POSTGRES_USER", "codigo")
+	// POSTGRES_PASSWORD must be set via environment variable (Kubernetes Secret)
+	// unless VAULT_POSTGRES_PATH points at the credentials in Vault.
+	// No default value for security - fail if neither is set
+	pass := os.Getenv("POSTGRES_PASSWORD")
+
+	var creds atomic.Pointer[credentials]
+	var opened atomic.Pointer[[]*pgxpool.Pool]
+	creds.Store(&credentials{user: user, password: pass})
+	if vaultPath := os.Getenv("VAULT_POSTGRES_PATH"); vault != nil && vaultPath != "" {
+		err := vault.Watch(ctx, vaultPath, logger, func(secret *config.Secret) {
+			c := credentials{user: secret.Field("username"), password: secret.Field("password")}
+			if c.user == "" {
+				c.user = user
+			}
+			creds.Store(&c)
+			// Connections opened with the previous credentials may stop
+			// working once their lease is revoked
+			if pools := opened.Load(); pools != nil {
+				for _, p := range *pools {
+					p.Reset()
+				}
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Postgres credentials from Vault: %w", err)
+		}
+	} else if pass == "" {
+		return nil, errors.New("POSTGRES_PASSWORD environment variable is required")
+	}
+
+	dsn := fmt.Sprintf("postgres://%s:%s/%s", host, port, db)
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.BeforeConnect = func(_ context.Context, cc *pgx.ConnConfig) error {
+		c := creds.Load()
+		cc.User = c.user
+		cc.Password = c.password
+		return nil
+	}
+	// Server-side statement_timeout backs up the per-query context timeouts
+	// in case a query is issued without one.
+	statementTimeout := config.Duration("DB_STATEMENT_TIMEOUT", 5*time.Second)
+	cfg.ConnConfig.RuntimeParams["statement_timeout"] = fmt.Sprintf("%d", statementTimeout.Milliseconds())
+	cfg.ConnConfig.Tracer = &SlowQueryTracer{
+		Logger:    logger,
+		Threshold: config.Duration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+	}
+
+	if len(tune) == 0 {
+		tune = append(tune, func(*pgxpool.Config) {})
+	}
+	pools := make([]*pgxpool.Pool, 0, len(tune))
+	for _, t := range tune {
+		c := cfg.Copy()
+		t(c)
+		p, err := pgxpool.NewWithConfig(ctx, c)
+		if err != nil {
+			for _, p := range pools {
+				p.Close()
+			}
+			return nil, err
+		}
+		pools = append(pools, p)
+	}
+	opened.Store(&pools)
+	return pools, nil
+}
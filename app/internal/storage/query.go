@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"codigo/internal/config"
+)
+
+// QueryTimeout bounds every named query issued through WithQuery.
+var QueryTimeout = config.Duration("DB_QUERY_TIMEOUT", 3*time.Second)
+
+type queryNameKey struct{}
+type queryStartKey struct{}
+
+type queryStart struct {
+	at  time.Time
+	sql string
+}
+
+// WithQuery names the query for slow-query logs and bounds it with the
+// configured per-query timeout.
+func WithQuery(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, queryNameKey{}, name)
+	return context.WithTimeout(ctx, QueryTimeout)
+}
+
+// SlowQueryTracer is a pgx QueryTracer that logs every query taking longer
+// than Threshold, so runaway queries show up instead of silently holding
+// pool connections.
+type SlowQueryTracer struct {
+	Logger    *zap.Logger
+	Threshold time.Duration
+}
+
+func (t *SlowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{at: time.Now(), sql: data.SQL})
+}
+
+func (t *SlowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	qs, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	duration := time.Since(qs.at)
+	if duration < t.Threshold {
+		return
+	}
+
+	name, _ := ctx.Value(queryNameKey{}).(string)
+	if name == "" {
+		name = "unnamed"
+	}
+	fields := []zap.Field{
+		zap.String("trace_id", trace.SpanContextFromContext(ctx).TraceID().String()),
+		zap.String("query_name", name),
+		zap.Duration("duration", duration),
+		zap.String("sql", qs.sql),
+	}
+	if data.Err != nil {
+		fields = append(fields, zap.Error(data.Err))
+	}
+	t.Logger.Warn("slow query", fields...)
+}
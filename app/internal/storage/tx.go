@@ -0,0 +1,71 @@
+// Package storage opens the Postgres pools and runs queries and
+// transactions on them the way both binaries need: bounded, named for the
+// slow-query log and retried on contention.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"codigo/internal/errs"
+)
+
+const (
+	txMaxAttempts      = 5
+	txBaseBackoff      = 10 * time.Millisecond
+	txStatementTimeout = 5 * time.Second
+)
+
+// WithTx runs fn in a transaction with a local statement timeout. The whole
+// transaction is retried with jittered exponential backoff when Postgres
+// aborts it with a serialization failure or deadlock, so fn must be safe to
+// run more than once. The time taken, retries included, is observed on
+// duration under a "commit" or "error" label value.
+func WithTx(ctx context.Context, db *pgxpool.Pool, duration prometheus.ObserverVec, fn func(pgx.Tx) error) error {
+	start := time.Now()
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = runTx(ctx, db, fn)
+		if err == nil || !errs.Is(err, errs.Contention) || attempt == txMaxAttempts {
+			break
+		}
+		backoff := txBaseBackoff<<(attempt-1) + rand.N(txBaseBackoff)
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(backoff):
+			continue
+		}
+		break
+	}
+
+	result := "commit"
+	if err != nil {
+		result = "error"
+	}
+	duration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func runTx(ctx context.Context, db *pgxpool.Pool, fn func(pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", txStatementTimeout.Milliseconds())); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
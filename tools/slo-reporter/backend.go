@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Metric stores the reporter can query. All of them serve the Prometheus
+// HTTP API; they differ in tenancy, lookback and partial responses.
+const (
+	backendPrometheus      = "prometheus"
+	backendVictoriaMetrics = "victoriametrics"
+	backendMimir           = "mimir"
+)
+
+// orgIDHeader selects the tenant on Mimir and Cortex.
+const orgIDHeader = "X-Scope-OrgID"
+
+// apiResponse is the envelope of every Prometheus API response, including
+// the fields VictoriaMetrics and Mimir add to it.
+type apiResponse struct {
+	Status    string          `json:"status"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+	Warnings  []string        `json:"warnings"`
+	IsPartial bool            `json:"isPartial"` // VictoriaMetrics cluster
+	Data      json.RawMessage `json:"data"`
+}
+
+// apiError is an error response from the API. Type is its errorType, e.g.
+// bad_data for a query that doesn't parse.
+type apiError struct {
+	Status  int
+	Type    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Prometheus query failed (status %d): %s: %s", e.Status, e.Type, e.Message)
+}
+
+// newClient returns the client for the flags' metric store.
+func (o *sloOptions) newClient() (*PrometheusClient, error) {
+	client := NewPrometheusClient(strings.TrimSuffix(o.prometheusURL, "/"))
+	switch o.backend {
+	case backendPrometheus, backendMimir:
+		if o.lookback > 0 {
+			return nil, fmt.Errorf("-lookback is only supported with -backend %s; %s sets it server side with --query.lookback-delta", backendVictoriaMetrics, o.backend)
+		}
+	case backendVictoriaMetrics:
+	default:
+		return nil, fmt.Errorf("unknown -backend %q; use %s, %s or %s", o.backend, backendPrometheus, backendVictoriaMetrics, backendMimir)
+	}
+	client.backend = o.backend
+	client.orgID = o.orgID
+	client.lookback = o.lookback
+	// Every instant query of a run is evaluated at the same time, so the
+	// SLOs of one report agree with each other.
+	client.at = time.Now()
+	return client, nil
+}
+
+// get calls the API at path with params and decodes its data into v.
+// Queries are POSTed as a form, which all supported stores accept and which
+// keeps long PromQL out of URL length limits.
+func (p *PrometheusClient) get(ctx context.Context, path string, params url.Values, v any) error {
+	if p.backend == backendVictoriaMetrics && p.lookback > 0 {
+		params.Set("max_lookback", p.lookback.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.orgID != "" {
+		req.Header.Set(orgIDHeader, p.orgID)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	var result apiResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Prometheus returned status %d: %s", resp.StatusCode, string(body))
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Status != "success" {
+		return &apiError{Status: resp.StatusCode, Type: result.ErrorType, Message: result.Error}
+	}
+	// A partial result undercounts and would make SLOs look healthier or
+	// worse than they are.
+	if result.IsPartial {
+		return fmt.Errorf("partial response: some storage nodes didn't answer")
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	if err := json.Unmarshal(result.Data, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
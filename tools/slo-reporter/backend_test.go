@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capturedRequest is what a fake metric store saw of one request.
+type capturedRequest struct {
+	method string
+	path   string
+	orgID  string
+	form   url.Values
+}
+
+// fakeStore answers every request with status and body and records the
+// last one.
+func fakeStore(t *testing.T, status int, body string) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	got := &capturedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+		*got = capturedRequest{method: r.Method, path: r.URL.Path, orgID: r.Header.Get(orgIDHeader), form: r.PostForm}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+const vectorResponse = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"route":"/v1/jobs"},"value":[1700000000,"0.995"]}]}}`
+
+func TestBackendRequests(t *testing.T) {
+	tests := []struct {
+		name         string
+		backend      string
+		prefix       string // path of the API root on the store
+		orgID        string
+		lookback     time.Duration
+		wantLookback string
+		wantNoCache  bool
+	}{
+		{name: "prometheus", backend: backendPrometheus},
+		{name: "victoriametrics single node", backend: backendVictoriaMetrics, wantNoCache: true},
+		{name: "victoriametrics cluster", backend: backendVictoriaMetrics, prefix: "/select/0/prometheus", wantNoCache: true},
+		{name: "victoriametrics lookback", backend: backendVictoriaMetrics, lookback: 5 * time.Minute, wantLookback: "5m0s", wantNoCache: true},
+		{name: "mimir", backend: backendMimir, prefix: "/prometheus", orgID: "codigo"},
+		{name: "mimir trailing slash", backend: backendMimir, prefix: "/prometheus/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, got := fakeStore(t, http.StatusOK, vectorResponse)
+			opts := &sloOptions{prometheusURL: srv.URL + tt.prefix, backend: tt.backend, orgID: tt.orgID, lookback: tt.lookback}
+			client, err := opts.newClient()
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			root := strings.TrimSuffix(tt.prefix, "/")
+
+			if _, err := client.Query(context.Background(), "up"); err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if got.method != http.MethodPost || got.path != root+"/api/v1/query" {
+				t.Errorf("instant query = %s %s, want POST %s/api/v1/query", got.method, got.path, root)
+			}
+			if got.form.Get("query") != "up" {
+				t.Errorf("instant query = %q, want up", got.form.Get("query"))
+			}
+			if want := strconv.FormatInt(client.at.Unix(), 10); got.form.Get("time") != want {
+				t.Errorf("instant query time = %q, want the run's evaluation time %s", got.form.Get("time"), want)
+			}
+			if got.orgID != tt.orgID {
+				t.Errorf("%s = %q, want %q", orgIDHeader, got.orgID, tt.orgID)
+			}
+			if lb := got.form.Get("max_lookback"); lb != tt.wantLookback {
+				t.Errorf("max_lookback = %q, want %q", lb, tt.wantLookback)
+			}
+
+			end := time.Unix(1700006400, 0)
+			if _, err := client.QueryRange(context.Background(), "up", end.Add(-time.Hour), end, 0); err != nil {
+				t.Fatalf("QueryRange() error = %v", err)
+			}
+			if got.path != root+"/api/v1/query_range" {
+				t.Errorf("range query path = %s, want %s/api/v1/query_range", got.path, root)
+			}
+			if got.form.Get("step") != "300" {
+				t.Errorf("range query step = %q, want the 300s default", got.form.Get("step"))
+			}
+			if noCache := got.form.Get("nocache") == "1"; noCache != tt.wantNoCache {
+				t.Errorf("range query nocache = %q, want nocache %v", got.form.Get("nocache"), tt.wantNoCache)
+			}
+		})
+	}
+}
+
+func TestNewClientRejectsInvalidBackends(t *testing.T) {
+	tests := []struct {
+		name string
+		opts sloOptions
+	}{
+		{"unknown backend", sloOptions{backend: "thanos"}},
+		{"prometheus lookback", sloOptions{backend: backendPrometheus, lookback: time.Minute}},
+		{"mimir lookback", sloOptions{backend: backendMimir, lookback: time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.opts.newClient(); err == nil {
+				t.Error("newClient() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestQueryVectorResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     []float64
+		wantErr  string
+		wantType string // errorType of an apiError
+	}{
+		{name: "vector", status: 200, body: vectorResponse, want: []float64{0.995}},
+		{name: "empty", status: 200, body: `{"status":"success","data":{"resultType":"vector","result":[]}}`, want: []float64{}},
+		{name: "NaN", status: 200, body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"NaN"]}]}}`, want: []float64{math.NaN()}},
+		{name: "warnings", status: 200, body: `{"status":"success","warnings":["query hit the series limit"],"data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"1"]}]}}`, want: []float64{1}},
+		{name: "bad query", status: 400, body: `{"status":"error","errorType":"bad_data","error":"parse error"}`, wantType: "bad_data"},
+		{name: "mimir tenant missing", status: 401, body: `{"status":"error","errorType":"unauthorized","error":"no org id"}`, wantType: "unauthorized"},
+		{name: "victoriametrics partial", status: 200, body: `{"status":"success","isPartial":true,"data":{"resultType":"vector","result":[]}}`, wantErr: "partial response"},
+		{name: "proxy error page", status: 502, body: `<html>Bad Gateway</html>`, wantErr: "status 502"},
+		{name: "value not a pair", status: 200, body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000]}]}}`, wantErr: "invalid value format"},
+		{name: "value not a string", status: 200, body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,1]}]}}`, wantErr: "invalid value format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := fakeStore(t, tt.status, tt.body)
+			samples, err := NewPrometheusClient(srv.URL).QueryVector(context.Background(), "up")
+			if tt.wantType != "" {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.Type != tt.wantType || apiErr.Status != tt.status {
+					t.Fatalf("QueryVector() error = %v, want apiError %s with status %d", err, tt.wantType, tt.status)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("QueryVector() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QueryVector() error = %v", err)
+			}
+			if len(samples) != len(tt.want) {
+				t.Fatalf("QueryVector() = %v, want %v", samples, tt.want)
+			}
+			for i, s := range samples {
+				if s.Value != tt.want[i] && !(math.IsNaN(s.Value) && math.IsNaN(tt.want[i])) {
+					t.Errorf("sample %d = %v, want %v", i, s.Value, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQueryRangeFillsGaps(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	body := `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1700000000,"1"],[1700000600,"0.5"],[1700009999,"2"]]}]}}`
+	srv, _ := fakeStore(t, http.StatusOK, body)
+	values, err := NewPrometheusClient(srv.URL).QueryRange(context.Background(), "up", start, start.Add(15*time.Minute), 5*time.Minute)
+	if err != nil {
+		t.Fatalf("QueryRange() error = %v", err)
+	}
+	// 0m, 5m (no sample), 10m, 15m (no sample); the sample past the end is
+	// dropped.
+	want := []float64{1, math.NaN(), 0.5, math.NaN()}
+	if len(values) != len(want) {
+		t.Fatalf("QueryRange() = %v, want %v", values, want)
+	}
+	for i := range want {
+		if values[i] != want[i] && !(math.IsNaN(values[i]) && math.IsNaN(want[i])) {
+			t.Errorf("QueryRange()[%d] = %v, want %v", i, values[i], want[i])
+		}
+	}
+}
+
+func TestRangeStep(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		span time.Duration
+		step time.Duration
+		want time.Duration
+	}{
+		{"default", time.Hour, 0, 5 * time.Minute},
+		{"given", time.Hour, time.Minute, time.Minute},
+		{"whole seconds", time.Hour, 1500 * time.Millisecond, 2 * time.Second},
+		{"too many points", 30 * 24 * time.Hour, time.Second, 236 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rangeStep(start, start.Add(tt.span), tt.step); got != tt.want {
+				t.Errorf("rangeStep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// command is one subcommand. run gets the arguments after the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands are the reporter's subcommands. Without one, or with only flags,
+// the reporter runs report, as it did before subcommands existed.
+var commands = []command{
+	{"report", "Evaluate the SLOs and print a text or JSON report (default)", runReport},
+	{"gate", "Print an allow/deny deployment decision and exit 2 on deny", runGate},
+	{"generate", "Print SLO definitions in another format: generate openslo", runGenerate},
+	{"validate", "Check every SLI query against the metric store and report dead ones", runValidate},
+}
+
+// errGateDenied makes main exit with status 2 without printing an error.
+var errGateDenied = errors.New("gate denied")
+
+// sloOptions are the flags every command shares for choosing the SLOs and
+// the Prometheus to evaluate them against.
+type sloOptions struct {
+	prometheusURL string
+	backend       string
+	orgID         string
+	lookback      time.Duration
+	manifestURL   string
+	sloFile       string
+	tenant        string
+	perTenant     bool
+}
+
+func (o *sloOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.prometheusURL, "prometheus-url", "http://localhost:9090", "Prometheus API base URL, e.g. http://mimir/prometheus or http://vmselect:8481/select/0/prometheus")
+	fs.StringVar(&o.backend, "backend", backendPrometheus, "Metric store: prometheus, victoriametrics or mimir")
+	fs.StringVar(&o.orgID, "org-id", "", "Tenant sent as X-Scope-OrgID (Mimir, Cortex)")
+	fs.DurationVar(&o.lookback, "lookback", 0, "VictoriaMetrics only: max_lookback for instant queries (default: server setting)")
+	fs.StringVar(&o.manifestURL, "manifest-url", "", "URL of a service SLO manifest (e.g. http://codigo-api:8080/slo-manifest.json); overrides the built-in SLOs")
+	fs.StringVar(&o.sloFile, "slo-file", "", "JSON file of SLO definitions, or OpenSLO YAML if it ends in .yaml/.yml (see README); overrides the built-in SLOs")
+	fs.StringVar(&o.tenant, "tenant", "", "Report SLOs for one tenant (needs METRICS_TENANT_DIMENSIONS=true on the API)")
+	fs.BoolVar(&o.perTenant, "per-tenant", false, "Report SLOs for every tenant seen in the window and summarize the worst offenders")
+}
+
+// definitions loads the SLOs the flags select. -per-tenant asks Prometheus
+// for the tenants, so it needs client.
+func (o *sloOptions) definitions(ctx context.Context, client *PrometheusClient) ([]SLODefinition, error) {
+	definitions := defaultSLOs()
+	if o.manifestURL != "" {
+		var err error
+		definitions, err = loadManifest(ctx, o.manifestURL)
+		if err != nil {
+			return nil, fmt.Errorf("loading SLO manifest: %w", err)
+		}
+	}
+	if o.sloFile != "" {
+		var err error
+		if isOpenSLOFile(o.sloFile) {
+			definitions, err = loadOpenSLO(o.sloFile)
+		} else {
+			definitions, err = loadSLOFile(o.sloFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading SLO file: %w", err)
+		}
+	}
+	if o.tenant != "" {
+		definitions = forTenant(definitions, o.tenant)
+	}
+	if o.perTenant && client != nil {
+		tenants, err := discoverTenants(ctx, client, definitions)
+		if err != nil {
+			return nil, fmt.Errorf("listing tenants: %w", err)
+		}
+		var sliced []SLODefinition
+		for _, t := range tenants {
+			sliced = append(sliced, forTenant(definitions, t)...)
+		}
+		definitions = sliced
+	}
+	return definitions, nil
+}
+
+// evaluate computes a report for each definition.
+func evaluate(ctx context.Context, client *PrometheusClient, definitions []SLODefinition) ([]*SLOReport, error) {
+	var reports []*SLOReport
+	for _, def := range definitions {
+		report, err := evaluateSLO(ctx, client, def)
+		if err != nil {
+			return nil, fmt.Errorf("calculating %s SLO: %w", def.Name, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// newFlagSet returns a flag set for the named command whose usage lists
+// the command's flags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: slo-reporter %s [flags]\n\nFlags:\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: slo-reporter [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'slo-reporter <command> -h' for the flags of a command.\n")
+}
+
+// dispatch picks the command named by the first argument and runs it with
+// the rest. Leading flags select report.
+func dispatch(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "-help" && args[0] != "--help" {
+		return runReport(ctx, args)
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(ctx, args[1:])
+		}
+	}
+	usage()
+	if args[0] == "help" || strings.HasPrefix(args[0], "-") {
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
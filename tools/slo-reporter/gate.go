@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// gateFlags are the thresholds of the gate command.
+type gateFlags struct {
+	maxBurnRate   *float64
+	minBudgetLeft *float64
+}
+
+func registerGateFlags(fs *flag.FlagSet) gateFlags {
+	return gateFlags{
+		maxBurnRate:   fs.Float64("gate-max-burn-rate", 1.0, "Gate: deny when any SLO burn rate exceeds this value"),
+		minBudgetLeft: fs.Float64("gate-min-budget-left", 0.2, "Gate: deny when any SLO has less than this fraction of error budget left"),
+	}
+}
+
+// runGate prints the gate decision as JSON and returns errGateDenied on deny,
+// so CD pipelines can block promotion on the exit status.
+func runGate(ctx context.Context, args []string) error {
+	var opts sloOptions
+	fs := newFlagSet("gate")
+	opts.register(fs)
+	flags := registerGateFlags(fs)
+	fs.Parse(args)
+	return gate(ctx, &opts, flags)
+}
+
+func gate(ctx context.Context, opts *sloOptions, flags gateFlags) error {
+	client, err := opts.newClient()
+	if err != nil {
+		return err
+	}
+	definitions, err := opts.definitions(ctx, client)
+	if err != nil {
+		return err
+	}
+	reports, err := evaluate(ctx, client, definitions)
+	if err != nil {
+		return err
+	}
+	decision := evaluateGate(reports, *flags.maxBurnRate, *flags.minBudgetLeft)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(decision); err != nil {
+		return fmt.Errorf("encoding JSON: %w", err)
+	}
+	if decision.Decision != "allow" {
+		return errGateDenied
+	}
+	return nil
+}
+
+// GateDecision is the deployment gate verdict printed for CD pipelines.
+type GateDecision struct {
+	Decision string   `json:"decision"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// evaluateGate denies promotion when any SLO burns faster than maxBurnRate
+// or has less than minBudgetLeft of its error budget remaining.
+func evaluateGate(reports []*SLOReport, maxBurnRate, minBudgetLeft float64) GateDecision {
+	var reasons []string
+	for _, report := range reports {
+		if report.BurnRate > maxBurnRate {
+			reasons = append(reasons, fmt.Sprintf("%s: burn rate %.2fx exceeds %.2fx", report.SLI, report.BurnRate, maxBurnRate))
+		}
+		if report.ErrorBudgetLeft < minBudgetLeft {
+			reasons = append(reasons, fmt.Sprintf("%s: %.2f%% error budget left, need %.2f%%", report.SLI, report.ErrorBudgetLeft*100, minBudgetLeft*100))
+		}
+	}
+	if len(reasons) > 0 {
+		return GateDecision{Decision: "deny", Reasons: reasons}
+	}
+	return GateDecision{Decision: "allow"}
+}
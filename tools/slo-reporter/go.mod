@@ -2,3 +2,4 @@ module codigo/slo-reporter
 
 go 1.22
 
+require gopkg.in/yaml.v3 v3.0.1
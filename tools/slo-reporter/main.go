@@ -3,12 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -23,6 +23,11 @@ const (
 type PrometheusClient struct {
 	baseURL string
 	client  *http.Client
+
+	backend  string        // backendPrometheus, backendVictoriaMetrics or backendMimir
+	orgID    string        // Mimir tenant, sent as X-Scope-OrgID
+	lookback time.Duration // VictoriaMetrics max_lookback; zero keeps the default
+	at       time.Time     // evaluation time of instant queries; zero means now
 }
 
 func NewPrometheusClient(baseURL string) *PrometheusClient {
@@ -35,87 +40,206 @@ func NewPrometheusClient(baseURL string) *PrometheusClient {
 }
 
 func (p *PrometheusClient) Query(ctx context.Context, query string) (float64, error) {
-	reqURL := fmt.Sprintf("%s/api/v1/query", p.baseURL)
-	params := url.Values{}
-	params.Add("query", query)
-
-	resp, err := p.client.Get(fmt.Sprintf("%s?%s", reqURL, params.Encode()))
+	samples, err := p.QueryVector(ctx, query)
 	if err != nil {
-		return 0, fmt.Errorf("failed to query Prometheus: %w", err)
+		return 0, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("Prometheus returned status %d: %s", resp.StatusCode, string(body))
+	if len(samples) == 0 {
+		return 0, fmt.Errorf("no data returned from query")
 	}
+	return samples[0].Value, nil
+}
 
-	var result struct {
-		Status string `json:"status"`
-		Data   struct {
-			ResultType string `json:"resultType"`
-			Result     []struct {
-				Value []interface{} `json:"value"`
-			} `json:"result"`
-		} `json:"data"`
-	}
+// promSample is one series of an instant query result.
+type promSample struct {
+	Labels map[string]string
+	Value  float64
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, fmt.Errorf("failed to decode response: %w", err)
+// QueryVector runs an instant query and returns every series in the result.
+func (p *PrometheusClient) QueryVector(ctx context.Context, query string) ([]promSample, error) {
+	params := url.Values{}
+	params.Add("query", query)
+	if !p.at.IsZero() {
+		params.Add("time", strconv.FormatInt(p.at.Unix(), 10))
 	}
 
-	if result.Status != "success" {
-		return 0, fmt.Errorf("Prometheus query failed: %s", result.Status)
+	var data struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
 	}
-
-	if len(result.Data.Result) == 0 {
-		return 0, fmt.Errorf("no data returned from query")
+	if err := p.get(ctx, "/api/v1/query", params, &data); err != nil {
+		return nil, err
 	}
 
-	// Parse the value (Prometheus returns [timestamp, value])
-	valueStr, ok := result.Data.Result[0].Value[1].(string)
-	if !ok {
-		return 0, fmt.Errorf("invalid value format")
-	}
+	samples := make([]promSample, 0, len(data.Result))
+	for _, r := range data.Result {
+		// Parse the value (Prometheus returns [timestamp, value])
+		if len(r.Value) != 2 {
+			return nil, fmt.Errorf("invalid value format")
+		}
+		valueStr, ok := r.Value[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value format")
+		}
 
-	var value float64
-	if _, err := fmt.Sscanf(valueStr, "%f", &value); err != nil {
-		return 0, fmt.Errorf("failed to parse value: %w", err)
+		// Values are strings so NaN and ±Inf survive JSON
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value: %w", err)
+		}
+		samples = append(samples, promSample{Labels: r.Metric, Value: value})
 	}
 
-	return value, nil
+	return samples, nil
 }
 
+// SLOReport is the evaluation of one SLO. The JSON field names are part of
+// the documented output schema (see reportSchemaVersion).
 type SLOReport struct {
-	SLI              string
-	CurrentValue     float64
-	Target           float64
-	ErrorBudget      float64
-	ErrorBudgetSpent float64
-	ErrorBudgetLeft  float64
-	BurnRate         float64
-	Status           string
+	SLI              string    `json:"sli"`
+	Kind             string    `json:"kind"`
+	Tenant           string    `json:"tenant,omitempty"`
+	CurrentValue     float64   `json:"current_value"`
+	Target           float64   `json:"target"`
+	ErrorBudget      float64   `json:"error_budget"`
+	ErrorBudgetSpent float64   `json:"error_budget_spent"`
+	ErrorBudgetLeft  float64   `json:"error_budget_left"`
+	BurnRate         float64   `json:"burn_rate"`
+	Status           string    `json:"status"` // statusHealthy, statusWarning or statusBreached
+	Daily            []float64 `json:"-"`      // per-day SLI values for the text report
+}
+
+const (
+	sliAvailability = "availability"
+	sliLatency      = "latency"
+	sliRatio        = "ratio"
+)
+
+// defaultBadCodes are the status codes that count against availability
+// unless an SLO says otherwise.
+const defaultBadCodes = "5.."
+
+// SLODefinition describes a single SLO to evaluate against Prometheus.
+type SLODefinition struct {
+	Name        string
+	Kind        string  // sliAvailability, sliLatency or sliRatio
+	Selector    string  // PromQL label matchers selecting the requests in scope
+	Target      float64 // availability ratio, or latency threshold in seconds
+	Percentile  float64 // latency only: quantile compared against Target
+	ErrorBudget float64 // latency only: fraction of requests allowed over Target
+	Tenant      string  // set when the selector is narrowed to one tenant
+	BadCodes    string  // availability only: regex of status codes counted as failures (default 5..)
+	GoodQuery   string  // ratio only: PromQL for good events; $window is replaced with the window
+	TotalQuery  string  // ratio only: PromQL for all events
+}
+
+// defaultSLOs returns the service-wide SLOs used when no manifest is given.
+func defaultSLOs() []SLODefinition {
+	return []SLODefinition{
+		{
+			Name:     "Availability",
+			Kind:     sliAvailability,
+			Selector: `service=~"codigo-api"`,
+			Target:   availabilityTarget,
+		},
+		{
+			Name:        "Latency (p95)",
+			Kind:        sliLatency,
+			Selector:    `service=~"codigo-api"`,
+			Target:      latencyTargetP95,
+			Percentile:  0.95,
+			ErrorBudget: 0.05,
+		},
+	}
+}
+
+func evaluateSLO(ctx context.Context, client *PrometheusClient, def SLODefinition) (*SLOReport, error) {
+	window := fmt.Sprintf("%dd", windowDays)
+	switch def.Kind {
+	case sliAvailability, sliRatio:
+		return calculateRatioSLO(ctx, client, def, sliQuery(def, window))
+	case sliLatency:
+		return calculateLatencySLO(ctx, client, def)
+	default:
+		return nil, fmt.Errorf("unknown SLI kind %q for %s", def.Kind, def.Name)
+	}
 }
 
-func calculateAvailabilitySLO(ctx context.Context, client *PrometheusClient) (*SLOReport, error) {
-	// Calculate current availability (30-day window)
-	// Availability = (non-5xx requests) / (total requests)
-	query := fmt.Sprintf(`
-		sum(rate(http_requests_total{service=~"codigo-api", code!~"5.."}[%dd])) 
+// sliQuery builds the PromQL for def's SLI over rng, e.g. "30d": the good
+// ratio for availability and ratio SLOs, the percentile for latency SLOs.
+func sliQuery(def SLODefinition, rng string) string {
+	switch def.Kind {
+	case sliAvailability:
+		// Availability = (requests without a bad status) / (total requests)
+		badCodes := def.BadCodes
+		if badCodes == "" {
+			badCodes = defaultBadCodes
+		}
+		return fmt.Sprintf(`
+		sum(rate(codigo_http_requests_total{%s, code!~%q}[%s])) 
 		/ 
-		sum(rate(http_requests_total{service=~"codigo-api"}[%dd]))
-	`, windowDays, windowDays)
+		sum(rate(codigo_http_requests_total{%s}[%s]))
+	`, def.Selector, badCodes, rng, def.Selector, rng)
+	case sliLatency:
+		return fmt.Sprintf(`
+		histogram_quantile(%g,
+			sum(rate(codigo_http_request_duration_seconds_bucket{%s}[%s]))
+			by (le, service)
+		)
+	`, def.Percentile, def.Selector, rng)
+	default:
+		good := strings.ReplaceAll(def.GoodQuery, "$window", rng)
+		total := strings.ReplaceAll(def.TotalQuery, "$window", rng)
+		return fmt.Sprintf("(%s) / (%s)", good, total)
+	}
+}
 
+const (
+	statusHealthy  = "healthy"
+	statusWarning  = "warning"
+	statusBreached = "breached"
+)
+
+func sloStatus(errorBudgetSpent float64) string {
+	status := statusHealthy
+	if errorBudgetSpent > 0.8 {
+		status = statusWarning
+	}
+	if errorBudgetSpent >= 1.0 {
+		status = statusBreached
+	}
+	return status
+}
+
+// statusLabel renders a status for the terminal.
+func statusLabel(status string) string {
+	switch status {
+	case statusHealthy:
+		return "✅ Healthy"
+	case statusWarning:
+		return "⚠️ Warning"
+	default:
+		return "❌ Breached"
+	}
+}
+
+// calculateRatioSLO evaluates an SLO whose SLI is the good/total ratio
+// returned by query.
+func calculateRatioSLO(ctx context.Context, client *PrometheusClient, def SLODefinition, query string) (*SLOReport, error) {
 	currentAvailability, err := client.Query(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query availability: %w", err)
+		return nil, fmt.Errorf("failed to query %s: %w", def.Kind, err)
 	}
 
 	// Calculate error rate
 	errorRate := 1 - currentAvailability
 
-	// Error budget: 0.1% (1 - 0.999)
-	errorBudget := 1 - availabilityTarget
+	// Error budget: 1 - target (0.1% for 99.9%)
+	errorBudget := 1 - def.Target
 
 	// Error budget spent
 	errorBudgetSpent := errorRate / errorBudget
@@ -126,58 +250,47 @@ func calculateAvailabilitySLO(ctx context.Context, client *PrometheusClient) (*S
 	// Burn rate: error rate / error budget (how fast we're burning through budget)
 	burnRate := errorRate / errorBudget
 
-	status := "✅ Healthy"
-	if errorBudgetSpent > 0.8 {
-		status = "⚠️ Warning"
-	}
-	if errorBudgetSpent >= 1.0 {
-		status = "❌ Breached"
-	}
-
 	return &SLOReport{
-		SLI:              "Availability",
+		SLI:              def.Name,
+		Kind:             def.Kind,
+		Tenant:           def.Tenant,
 		CurrentValue:     currentAvailability,
-		Target:           availabilityTarget,
+		Target:           def.Target,
 		ErrorBudget:      errorBudget,
 		ErrorBudgetSpent: errorBudgetSpent,
 		ErrorBudgetLeft:  errorBudgetLeft,
 		BurnRate:         burnRate,
-		Status:           status,
+		Status:           sloStatus(errorBudgetSpent),
 	}, nil
 }
 
-func calculateLatencySLO(ctx context.Context, client *PrometheusClient) (*SLOReport, error) {
-	// Calculate current p95 latency (30-day window)
-	query := fmt.Sprintf(`
-		histogram_quantile(0.95,
-			sum(rate(http_request_duration_seconds_bucket{service=~"codigo-api"}[%dd]))
-			by (le, service)
-		)
-	`, windowDays)
+func calculateLatencySLO(ctx context.Context, client *PrometheusClient, def SLODefinition) (*SLOReport, error) {
+	// Calculate current latency percentile (30-day window)
+	query := sliQuery(def, fmt.Sprintf("%dd", windowDays))
 
 	currentLatency, err := client.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query latency: %w", err)
 	}
 
-	// Calculate percentage of requests meeting SLO (p95 ≤ 500ms)
-	// Query for requests below threshold (simplified: if p95 > target, estimate violations)
+	// Error budget: share of requests allowed to exceed the target
+	errorBudget := def.ErrorBudget
+
+	// Calculate percentage of requests meeting SLO (e.g. p95 ≤ 500ms)
+	// Query for requests below threshold (simplified: if the percentile exceeds target, estimate violations)
 	meetingSLO := 1.0
-	if currentLatency > latencyTargetP95 {
-		// Estimate: calculate how much p95 exceeds target
+	if currentLatency > def.Target {
+		// Estimate: calculate how much the percentile exceeds target
 		// If p95 is 600ms and target is 500ms, we estimate ~10-15% violations
-		excessRatio := (currentLatency - latencyTargetP95) / latencyTargetP95
-		// Cap at 5% (error budget limit)
+		excessRatio := (currentLatency - def.Target) / def.Target
+		// Cap at the error budget limit
 		violationRate := excessRatio * 0.1 // Conservative estimate
-		if violationRate > 0.05 {
-			violationRate = 0.05
+		if violationRate > errorBudget {
+			violationRate = errorBudget
 		}
 		meetingSLO = 1 - violationRate
 	}
 
-	// Error budget: 5% (requests can exceed 500ms)
-	errorBudget := 0.05
-
 	// Error budget spent
 	errorBudgetSpent := (1 - meetingSLO) / errorBudget
 
@@ -187,23 +300,17 @@ func calculateLatencySLO(ctx context.Context, client *PrometheusClient) (*SLORep
 	// Burn rate
 	burnRate := (1 - meetingSLO) / errorBudget
 
-	status := "✅ Healthy"
-	if errorBudgetSpent > 0.8 {
-		status = "⚠️ Warning"
-	}
-	if errorBudgetSpent >= 1.0 {
-		status = "❌ Breached"
-	}
-
 	return &SLOReport{
-		SLI:              "Latency (p95)",
+		SLI:              def.Name,
+		Kind:             sliLatency,
+		Tenant:           def.Tenant,
 		CurrentValue:     currentLatency,
-		Target:           latencyTargetP95,
+		Target:           def.Target,
 		ErrorBudget:      errorBudget,
 		ErrorBudgetSpent: errorBudgetSpent,
 		ErrorBudgetLeft:  errorBudgetLeft,
 		BurnRate:         burnRate,
-		Status:           status,
+		Status:           sloStatus(errorBudgetSpent),
 	}, nil
 }
 
@@ -217,16 +324,16 @@ func printReport(reports []*SLOReport) {
 	for _, report := range reports {
 		fmt.Println(strings.Repeat("-", 80))
 		fmt.Printf("SLO: %s\n", report.SLI)
-		fmt.Printf("Status: %s\n", report.Status)
+		fmt.Printf("Status: %s\n", statusLabel(report.Status))
 		fmt.Printf("Current Value: %.4f\n", report.CurrentValue)
 		fmt.Printf("Target: %.4f\n", report.Target)
 
-		if report.SLI == "Availability" {
+		if report.Kind != sliLatency {
 			fmt.Printf("Current Availability: %.2f%%\n", report.CurrentValue*100)
 			fmt.Printf("Target Availability: %.2f%%\n", report.Target*100)
 		} else {
-			fmt.Printf("Current p95 Latency: %.0fms\n", report.CurrentValue*1000)
-			fmt.Printf("Target p95 Latency: %.0fms\n", report.Target*1000)
+			fmt.Printf("Current Latency: %.0fms\n", report.CurrentValue*1000)
+			fmt.Printf("Target Latency: %.0fms\n", report.Target*1000)
 		}
 
 		fmt.Printf("\nError Budget:\n")
@@ -239,6 +346,7 @@ func printReport(reports []*SLOReport) {
 			daysUntilExhaustion := windowDays / report.BurnRate
 			fmt.Printf("  ⚠️  At current burn rate, error budget will be exhausted in ~%.0f days\n", daysUntilExhaustion)
 		}
+		printTrend(report)
 
 		fmt.Println()
 	}
@@ -246,41 +354,73 @@ func printReport(reports []*SLOReport) {
 	fmt.Println(strings.Repeat("=", 80))
 }
 
-func main() {
-	var (
-		prometheusURL = flag.String("prometheus-url", "http://localhost:9090", "Prometheus base URL")
-		output        = flag.String("output", "text", "Output format: text or json")
-	)
-	flag.Parse()
-
-	ctx := context.Background()
-	client := NewPrometheusClient(*prometheusURL)
+// runReport evaluates the SLOs and prints the text report, or the JSON
+// document with -output json. The -gate and -export-openslo flags of the
+// single-command CLI are still accepted and run gate and generate openslo.
+func runReport(ctx context.Context, args []string) error {
+	var opts sloOptions
+	fs := newFlagSet("report")
+	opts.register(fs)
+	output := fs.String("output", "text", "Output format: text or json")
+	gateMode := fs.Bool("gate", false, "Deprecated: use the gate command")
+	exportOpenSLO := fs.Bool("export-openslo", false, "Deprecated: use generate openslo")
+	gateFlags := registerGateFlags(fs)
+	sloService := fs.String("openslo-service", "codigo-api", "Deprecated: use generate openslo")
+	fs.Parse(args)
+
+	if *exportOpenSLO {
+		return generateOpenSLO(ctx, &opts, *sloService)
+	}
+	if *gateMode {
+		return gate(ctx, &opts, gateFlags)
+	}
 
-	// Calculate SLOs
-	availabilityReport, err := calculateAvailabilitySLO(ctx, client)
+	client, err := opts.newClient()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error calculating availability SLO: %v\n", err)
-		os.Exit(1)
+		return err
 	}
-
-	latencyReport, err := calculateLatencySLO(ctx, client)
+	definitions, err := opts.definitions(ctx, client)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error calculating latency SLO: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+	reports, err := evaluate(ctx, client, definitions)
+	if err != nil {
+		return err
 	}
 
-	reports := []*SLOReport{availabilityReport, latencyReport}
-
-	// Output
 	if *output == "json" {
 		encoder := json.NewEncoder(os.Stdout)
 		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(reports); err != nil {
-			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
-			os.Exit(1)
+		if err := encoder.Encode(newReportDocument(opts.prometheusURL, time.Now(), reports)); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
 		}
-	} else {
-		printReport(reports)
+		return nil
+	}
+
+	// Daily trend for the text report; a failure only loses the sparklines
+	now := time.Now()
+	for i, report := range reports {
+		daily, err := dailyTrend(ctx, client, definitions[i], now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: no daily trend for %s: %v\n", report.SLI, err)
+			continue
+		}
+		report.Daily = daily
+	}
+	printReport(reports)
+	if opts.perTenant {
+		printWorstTenants(reports, worstTenantsShown)
 	}
+	return nil
 }
 
+func main() {
+	err := dispatch(context.Background(), os.Args[1:])
+	switch {
+	case errors.Is(err, errGateDenied):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
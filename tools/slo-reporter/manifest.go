@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// sloManifest mirrors the document served by the API at /slo-manifest.json.
+type sloManifest struct {
+	Service string `json:"service"`
+	Routes  []struct {
+		Method               string  `json:"method"`
+		Route                string  `json:"route"`
+		LatencyTargetSeconds float64 `json:"latency_target_seconds"`
+		LatencyPercentile    float64 `json:"latency_percentile"`
+		AvailabilityClass    string  `json:"availability_class"`
+		AvailabilityTarget   float64 `json:"availability_target"`
+	} `json:"routes"`
+}
+
+// loadManifest fetches a service SLO manifest and turns every declared route
+// into an availability and a latency SLO definition.
+func loadManifest(ctx context.Context, manifestURL string) ([]SLODefinition, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest URL: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("manifest endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var manifest sloManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if manifest.Service == "" || len(manifest.Routes) == 0 {
+		return nil, fmt.Errorf("manifest declares no routes")
+	}
+
+	var defs []SLODefinition
+	for _, rt := range manifest.Routes {
+		selector := fmt.Sprintf(`service=%q, route=%q, method=%q`, manifest.Service, rt.Route, rt.Method)
+		if rt.AvailabilityTarget > 0 {
+			defs = append(defs, SLODefinition{
+				Name:     fmt.Sprintf("Availability %s %s (%s)", rt.Method, rt.Route, rt.AvailabilityClass),
+				Kind:     sliAvailability,
+				Selector: selector,
+				Target:   rt.AvailabilityTarget,
+			})
+		}
+		if rt.LatencyTargetSeconds > 0 && rt.LatencyPercentile > 0 && rt.LatencyPercentile < 1 {
+			defs = append(defs, SLODefinition{
+				Name:        fmt.Sprintf("Latency (p%g) %s %s", rt.LatencyPercentile*100, rt.Method, rt.Route),
+				Kind:        sliLatency,
+				Selector:    selector,
+				Target:      rt.LatencyTargetSeconds,
+				Percentile:  rt.LatencyPercentile,
+				ErrorBudget: 1 - rt.LatencyPercentile,
+			})
+		}
+	}
+	return defs, nil
+}
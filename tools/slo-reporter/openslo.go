@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	opensloAPIVersion = "openslo/v1"
+
+	// opensloWindow is the OpenSLO placeholder for the query range, as used
+	// by Sloth. It maps to $window in ratio queries.
+	opensloWindow = "{{.window}}"
+
+	// Annotations that carry what OpenSLO can't express, so exported
+	// availability and latency SLOs import back as themselves rather than
+	// as ratio SLOs.
+	annotationKind       = "codigo.dev/kind"
+	annotationSelector   = "codigo.dev/selector"
+	annotationBadCodes   = "codigo.dev/bad-codes"
+	annotationPercentile = "codigo.dev/percentile"
+)
+
+// runGenerate prints the selected SLO definitions in another format. Only
+// openslo is supported.
+func runGenerate(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("generate needs a format: generate openslo")
+	}
+	format := args[0]
+	if format != "openslo" {
+		return fmt.Errorf("unknown format %q; supported: openslo", format)
+	}
+	var opts sloOptions
+	fs := newFlagSet("generate openslo")
+	opts.register(fs)
+	service := fs.String("openslo-service", "codigo-api", "Service name written to exported OpenSLO documents")
+	fs.Parse(args[1:])
+	return generateOpenSLO(ctx, &opts, *service)
+}
+
+// generateOpenSLO prints the definitions as OpenSLO YAML without querying
+// Prometheus, so -per-tenant has no effect.
+func generateOpenSLO(ctx context.Context, opts *sloOptions, service string) error {
+	definitions, err := opts.definitions(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := writeOpenSLO(os.Stdout, service, definitions); err != nil {
+		return fmt.Errorf("writing OpenSLO: %w", err)
+	}
+	return nil
+}
+
+// opensloDoc is the subset of an OpenSLO v1 document the reporter reads and
+// writes: SLO documents, and SLI documents referenced by indicatorRef.
+type opensloDoc struct {
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Metadata   opensloMetadata `yaml:"metadata"`
+	Spec       yaml.Node       `yaml:"spec"`
+}
+
+type opensloMetadata struct {
+	Name        string            `yaml:"name"`
+	DisplayName string            `yaml:"displayName,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type opensloSLOSpec struct {
+	Description     string              `yaml:"description,omitempty"`
+	Service         string              `yaml:"service"`
+	Indicator       *opensloSLI         `yaml:"indicator,omitempty"`
+	IndicatorRef    string              `yaml:"indicatorRef,omitempty"`
+	TimeWindow      []opensloTimeWindow `yaml:"timeWindow"`
+	BudgetingMethod string              `yaml:"budgetingMethod"`
+	Objectives      []opensloObjective  `yaml:"objectives"`
+}
+
+type opensloSLI struct {
+	Metadata opensloMetadata `yaml:"metadata"`
+	Spec     opensloSLISpec  `yaml:"spec"`
+}
+
+type opensloSLISpec struct {
+	RatioMetric     *opensloRatioMetric `yaml:"ratioMetric,omitempty"`
+	ThresholdMetric *opensloMetric      `yaml:"thresholdMetric,omitempty"`
+}
+
+type opensloRatioMetric struct {
+	Counter bool           `yaml:"counter"`
+	Good    *opensloMetric `yaml:"good,omitempty"`
+	Bad     *opensloMetric `yaml:"bad,omitempty"`
+	Total   *opensloMetric `yaml:"total"`
+}
+
+type opensloMetric struct {
+	MetricSource struct {
+		Type string `yaml:"type"`
+		Spec struct {
+			Query string `yaml:"query"`
+		} `yaml:"spec"`
+	} `yaml:"metricSource"`
+}
+
+type opensloTimeWindow struct {
+	Duration  string `yaml:"duration"`
+	IsRolling bool   `yaml:"isRolling"`
+}
+
+type opensloObjective struct {
+	DisplayName string  `yaml:"displayName,omitempty"`
+	Target      float64 `yaml:"target"`
+}
+
+// isOpenSLOFile reports whether an -slo-file path should be read as OpenSLO
+// YAML rather than the reporter's JSON format.
+func isOpenSLOFile(path string) bool {
+	return strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+}
+
+// loadOpenSLO reads SLO definitions from a multi-document OpenSLO v1 YAML
+// file. Only Prometheus ratio indicators can be evaluated; every objective
+// of an SLO becomes its own definition. The reporter always uses its own
+// 30-day window, whatever timeWindow says.
+func loadOpenSLO(path string) ([]SLODefinition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var slos []opensloDoc
+	slis := make(map[string]opensloSLI)
+	dec := yaml.NewDecoder(f)
+	for {
+		var doc opensloDoc
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		if doc.APIVersion != opensloAPIVersion {
+			return nil, fmt.Errorf("%s: unsupported apiVersion %q (want %s)", doc.Metadata.Name, doc.APIVersion, opensloAPIVersion)
+		}
+		switch doc.Kind {
+		case "SLO":
+			slos = append(slos, doc)
+		case "SLI":
+			var spec opensloSLISpec
+			if err := doc.Spec.Decode(&spec); err != nil {
+				return nil, fmt.Errorf("SLI %s: %w", doc.Metadata.Name, err)
+			}
+			slis[doc.Metadata.Name] = opensloSLI{Metadata: doc.Metadata, Spec: spec}
+		}
+		// Service, AlertPolicy and other kinds have nothing to evaluate.
+	}
+	if len(slos) == 0 {
+		return nil, fmt.Errorf("%s declares no SLOs", path)
+	}
+
+	var defs []SLODefinition
+	for _, doc := range slos {
+		name := doc.Metadata.DisplayName
+		if name == "" {
+			name = doc.Metadata.Name
+		}
+		var spec opensloSLOSpec
+		if err := doc.Spec.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("SLO %s: %w", name, err)
+		}
+		sli := spec.Indicator
+		if sli == nil {
+			ref, ok := slis[spec.IndicatorRef]
+			if !ok {
+				return nil, fmt.Errorf("SLO %s: indicator %q not found", name, spec.IndicatorRef)
+			}
+			sli = &ref
+		}
+		if len(spec.Objectives) == 0 {
+			return nil, fmt.Errorf("SLO %s has no objectives", name)
+		}
+
+		for _, obj := range spec.Objectives {
+			def, err := openSLODefinition(doc.Metadata.Annotations, sli, obj.Target)
+			if err != nil {
+				return nil, fmt.Errorf("SLO %s: %w", name, err)
+			}
+			def.Name = name
+			if obj.DisplayName != "" && len(spec.Objectives) > 1 {
+				def.Name = fmt.Sprintf("%s (%s)", name, obj.DisplayName)
+			}
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+// openSLODefinition converts one objective of an OpenSLO SLO. SLOs exported
+// by the reporter are restored from their annotations; anything else must
+// be a Prometheus ratio indicator.
+func openSLODefinition(annotations map[string]string, sli *opensloSLI, target float64) (SLODefinition, error) {
+	if target <= 0 || target >= 1 {
+		return SLODefinition{}, fmt.Errorf("objective target %g is not between 0 and 1", target)
+	}
+
+	kind := annotations[annotationKind]
+	if (kind == sliAvailability || kind == sliLatency) && annotations[annotationSelector] == "" {
+		return SLODefinition{}, fmt.Errorf("%s SLO has no %s annotation", kind, annotationSelector)
+	}
+	switch kind {
+	case sliAvailability:
+		return SLODefinition{
+			Kind:     sliAvailability,
+			Selector: annotations[annotationSelector],
+			Target:   target,
+			BadCodes: annotations[annotationBadCodes],
+		}, nil
+	case sliLatency:
+		percentile, err := strconv.ParseFloat(annotations[annotationPercentile], 64)
+		if err != nil || percentile <= 0 || percentile >= 1 {
+			return SLODefinition{}, fmt.Errorf("invalid %s annotation %q", annotationPercentile, annotations[annotationPercentile])
+		}
+		threshold, err := latencyThreshold(sli)
+		if err != nil {
+			return SLODefinition{}, err
+		}
+		return SLODefinition{
+			Kind:        sliLatency,
+			Selector:    annotations[annotationSelector],
+			Target:      threshold,
+			Percentile:  percentile,
+			ErrorBudget: 1 - target,
+		}, nil
+	}
+
+	ratio := sli.Spec.RatioMetric
+	if ratio == nil {
+		return SLODefinition{}, fmt.Errorf("only ratioMetric indicators are supported")
+	}
+	if ratio.Total == nil || (ratio.Good == nil) == (ratio.Bad == nil) {
+		return SLODefinition{}, fmt.Errorf("ratioMetric needs total and exactly one of good or bad")
+	}
+	total, err := prometheusQuery(ratio.Total)
+	if err != nil {
+		return SLODefinition{}, err
+	}
+	var good string
+	if ratio.Good != nil {
+		good, err = prometheusQuery(ratio.Good)
+	} else {
+		var bad string
+		bad, err = prometheusQuery(ratio.Bad)
+		good = fmt.Sprintf("(%s) - (%s)", total, bad)
+	}
+	if err != nil {
+		return SLODefinition{}, err
+	}
+	return SLODefinition{
+		Kind:       sliRatio,
+		Target:     target,
+		GoodQuery:  good,
+		TotalQuery: total,
+	}, nil
+}
+
+var windowPlaceholder = regexp.MustCompile(`\{\{\s*\.window\s*\}\}`)
+
+// prometheusQuery returns the query of a Prometheus metric source with the
+// OpenSLO window placeholder replaced by $window.
+func prometheusQuery(m *opensloMetric) (string, error) {
+	if !strings.EqualFold(m.MetricSource.Type, "prometheus") {
+		return "", fmt.Errorf("unsupported metric source %q", m.MetricSource.Type)
+	}
+	if m.MetricSource.Spec.Query == "" {
+		return "", fmt.Errorf("metric source has no query")
+	}
+	return windowPlaceholder.ReplaceAllString(strings.TrimSpace(m.MetricSource.Spec.Query), "$$window"), nil
+}
+
+var bucketBound = regexp.MustCompile(`le="([^"]+)"`)
+
+// latencyThreshold recovers the latency target of an exported latency SLO
+// from the le bucket of its good query.
+func latencyThreshold(sli *opensloSLI) (float64, error) {
+	if sli.Spec.RatioMetric == nil || sli.Spec.RatioMetric.Good == nil {
+		return 0, fmt.Errorf("latency SLO has no good query")
+	}
+	m := bucketBound.FindStringSubmatch(sli.Spec.RatioMetric.Good.MetricSource.Spec.Query)
+	if m == nil {
+		return 0, fmt.Errorf("latency SLO good query has no le bucket")
+	}
+	return strconv.ParseFloat(m[1], 64)
+}
+
+// writeOpenSLO writes defs as OpenSLO v1 SLO documents with inline ratio
+// indicators, the form Sloth and Nobl9 import. Latency SLOs become the
+// share of requests in the histogram bucket at the target, so the target
+// must be one of the bucket bounds of codigo_http_request_duration_seconds.
+func writeOpenSLO(w io.Writer, service string, defs []SLODefinition) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, def := range defs {
+		annotations := map[string]string{annotationKind: def.Kind}
+		var good, total string
+		target := def.Target
+		switch def.Kind {
+		case sliAvailability:
+			badCodes := def.BadCodes
+			if badCodes == "" {
+				badCodes = defaultBadCodes
+			}
+			annotations[annotationSelector] = def.Selector
+			annotations[annotationBadCodes] = badCodes
+			good = fmt.Sprintf(`sum(rate(codigo_http_requests_total{%s, code!~%q}[%s]))`, def.Selector, badCodes, opensloWindow)
+			total = fmt.Sprintf(`sum(rate(codigo_http_requests_total{%s}[%s]))`, def.Selector, opensloWindow)
+		case sliLatency:
+			annotations[annotationSelector] = def.Selector
+			annotations[annotationPercentile] = strconv.FormatFloat(def.Percentile, 'g', -1, 64)
+			good = fmt.Sprintf(`sum(rate(codigo_http_request_duration_seconds_bucket{%s, le="%g"}[%s]))`, def.Selector, def.Target, opensloWindow)
+			total = fmt.Sprintf(`sum(rate(codigo_http_request_duration_seconds_count{%s}[%s]))`, def.Selector, opensloWindow)
+			target = 1 - def.ErrorBudget
+		default:
+			delete(annotations, annotationKind)
+			good = strings.ReplaceAll(def.GoodQuery, "$window", opensloWindow)
+			total = strings.ReplaceAll(def.TotalQuery, "$window", opensloWindow)
+		}
+
+		name := opensloName(def.Name)
+		spec := opensloSLOSpec{
+			Service: service,
+			Indicator: &opensloSLI{
+				Metadata: opensloMetadata{Name: name},
+				Spec: opensloSLISpec{RatioMetric: &opensloRatioMetric{
+					Counter: true,
+					Good:    prometheusMetric(good),
+					Total:   prometheusMetric(total),
+				}},
+			},
+			TimeWindow:      []opensloTimeWindow{{Duration: fmt.Sprintf("%dd", windowDays), IsRolling: true}},
+			BudgetingMethod: "Occurrences",
+			Objectives:      []opensloObjective{{Target: target}},
+		}
+		doc := opensloDoc{
+			APIVersion: opensloAPIVersion,
+			Kind:       "SLO",
+			Metadata:   opensloMetadata{Name: name, DisplayName: def.Name, Annotations: annotations},
+		}
+		if err := doc.Spec.Encode(spec); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+func prometheusMetric(query string) *opensloMetric {
+	m := &opensloMetric{}
+	m.MetricSource.Type = "Prometheus"
+	m.MetricSource.Spec.Query = query
+	return m
+}
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// opensloName turns an SLO name into an OpenSLO metadata.name, which must
+// be a lowercase DNS label.
+func opensloName(name string) string {
+	s := strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-")
+	}
+	return s
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"time"
+)
+
+// reportSchemaVersion is bumped whenever a field of reportDocument or
+// SLOReport is renamed or removed. Adding fields keeps the version.
+const reportSchemaVersion = 1
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// reportDocument is the top level of -output json.
+type reportDocument struct {
+	SchemaVersion int          `json:"schema_version"`
+	ToolVersion   string       `json:"tool_version"`
+	GeneratedAt   time.Time    `json:"generated_at"`
+	WindowStart   time.Time    `json:"window_start"`
+	WindowEnd     time.Time    `json:"window_end"`
+	WindowDays    int          `json:"window_days"`
+	PrometheusURL string       `json:"prometheus_url"`
+	SLOs          []*SLOReport `json:"slos"`
+}
+
+func newReportDocument(prometheusURL string, now time.Time, reports []*SLOReport) reportDocument {
+	now = now.UTC()
+	if reports == nil {
+		reports = []*SLOReport{}
+	}
+	return reportDocument{
+		SchemaVersion: reportSchemaVersion,
+		ToolVersion:   version,
+		GeneratedAt:   now,
+		WindowStart:   now.Add(-windowDays * 24 * time.Hour),
+		WindowEnd:     now,
+		WindowDays:    windowDays,
+		PrometheusURL: prometheusURL,
+		SLOs:          reports,
+	}
+}
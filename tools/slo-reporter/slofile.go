@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// sloFileEntry is one SLO in a -slo-file document.
+type sloFileEntry struct {
+	Name        string  `json:"name"`
+	Kind        string  `json:"kind"`
+	Selector    string  `json:"selector"`
+	Target      float64 `json:"target"`
+	Percentile  float64 `json:"percentile"`
+	ErrorBudget float64 `json:"error_budget"`
+	BadCodes    string  `json:"bad_codes"`
+	GoodQuery   string  `json:"good_query"`
+	TotalQuery  string  `json:"total_query"`
+}
+
+// loadSLOFile reads SLO definitions from a JSON array of sloFileEntry.
+func loadSLOFile(path string) ([]SLODefinition, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []sloFileEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s declares no SLOs", path)
+	}
+
+	defs := make([]SLODefinition, 0, len(entries))
+	for i, e := range entries {
+		if e.Name == "" {
+			return nil, fmt.Errorf("SLO %d has no name", i)
+		}
+		switch e.Kind {
+		case sliAvailability:
+			if e.Selector == "" || e.Target <= 0 || e.Target >= 1 {
+				return nil, fmt.Errorf("%s: availability SLOs need a selector and a target between 0 and 1", e.Name)
+			}
+			if e.BadCodes != "" {
+				if _, err := regexp.Compile(e.BadCodes); err != nil {
+					return nil, fmt.Errorf("%s: invalid bad_codes: %w", e.Name, err)
+				}
+			}
+		case sliLatency:
+			if e.Selector == "" || e.Target <= 0 || e.Percentile <= 0 || e.Percentile >= 1 {
+				return nil, fmt.Errorf("%s: latency SLOs need a selector, a target in seconds and a percentile between 0 and 1", e.Name)
+			}
+			if e.ErrorBudget == 0 {
+				e.ErrorBudget = 1 - e.Percentile
+			}
+		case sliRatio:
+			if e.GoodQuery == "" || e.TotalQuery == "" || e.Target <= 0 || e.Target >= 1 {
+				return nil, fmt.Errorf("%s: ratio SLOs need good_query, total_query and a target between 0 and 1", e.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown kind %q", e.Name, e.Kind)
+		}
+		defs = append(defs, SLODefinition{
+			Name:        e.Name,
+			Kind:        e.Kind,
+			Selector:    e.Selector,
+			Target:      e.Target,
+			Percentile:  e.Percentile,
+			ErrorBudget: e.ErrorBudget,
+			BadCodes:    e.BadCodes,
+			GoodQuery:   e.GoodQuery,
+			TotalQuery:  e.TotalQuery,
+		})
+	}
+	return defs, nil
+}
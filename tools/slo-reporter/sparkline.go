@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// maxRangePoints is the most points per series Prometheus returns from a
+// range query; VictoriaMetrics and Mimir allow more.
+const maxRangePoints = 11000
+
+// rangeStep returns step, or when it's zero the step each store would use,
+// raised to whole seconds and so that the range fits in maxRangePoints.
+func rangeStep(start, end time.Time, step time.Duration) time.Duration {
+	if step <= 0 {
+		// VictoriaMetrics defaults to 5m; Prometheus and Mimir require a step
+		step = 5 * time.Minute
+	}
+	if floor := end.Sub(start) / maxRangePoints; step < floor {
+		step = floor
+	}
+	if rem := step % time.Second; rem != 0 {
+		step += time.Second - rem
+	}
+	return step
+}
+
+// QueryRange runs a range query and returns the values of the first series
+// at each step from start to end. Steps without a sample are NaN. A zero
+// step picks a default.
+func (p *PrometheusClient) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]float64, error) {
+	step = rangeStep(start, end, step)
+	params := url.Values{}
+	params.Add("query", query)
+	params.Add("start", strconv.FormatInt(start.Unix(), 10))
+	params.Add("end", strconv.FormatInt(end.Unix(), 10))
+	params.Add("step", strconv.Itoa(int(step.Seconds())))
+
+	if p.backend == backendVictoriaMetrics {
+		// The cached last step would otherwise lag behind the window
+		params.Add("nocache", "1")
+	}
+
+	var data struct {
+		Result []struct {
+			Values [][]interface{} `json:"values"`
+		} `json:"result"`
+	}
+	if err := p.get(ctx, "/api/v1/query_range", params, &data); err != nil {
+		return nil, err
+	}
+
+	n := int(end.Sub(start)/step) + 1
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	if len(data.Result) == 0 {
+		return values, nil
+	}
+	for _, v := range data.Result[0].Values {
+		if len(v) != 2 {
+			continue
+		}
+		ts, ok1 := v[0].(float64)
+		str, ok2 := v[1].(string)
+		if !ok1 || !ok2 {
+			continue
+		}
+		i := int(math.Round((ts - float64(start.Unix())) / step.Seconds()))
+		if f, err := strconv.ParseFloat(str, 64); err == nil && i >= 0 && i < n {
+			values[i] = f
+		}
+	}
+	return values, nil
+}
+
+// dailyTrend fills in the per-day SLI values over the window, used for the
+// sparklines of the text report.
+func dailyTrend(ctx context.Context, client *PrometheusClient, def SLODefinition, now time.Time) ([]float64, error) {
+	day := 24 * time.Hour
+	end := now.Truncate(day)
+	start := end.Add(-time.Duration(windowDays-1) * day)
+	return client.QueryRange(ctx, sliQuery(def, "1d"), start, end, day)
+}
+
+// sparkline renders values scaled between lo and hi; NaN (no traffic) is a
+// blank.
+func sparkline(values []float64, lo, hi float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		if math.IsNaN(v) {
+			b.WriteRune(' ')
+			continue
+		}
+		i := 0
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		i = max(0, min(i, len(sparkBlocks)-1))
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// printTrend prints the daily sparklines for one report. Availability and
+// ratio SLOs show the daily error rate and the budget left at the end of
+// each day, assuming traffic is spread evenly over the window; latency SLOs
+// show the daily percentile.
+func printTrend(report *SLOReport) {
+	if len(report.Daily) == 0 {
+		return
+	}
+	fmt.Printf("\nLast %d days (oldest first):\n", len(report.Daily))
+	if report.Kind == sliLatency {
+		hi := report.Target
+		for _, v := range report.Daily {
+			if !math.IsNaN(v) {
+				hi = math.Max(hi, v)
+			}
+		}
+		fmt.Printf("  Daily latency   %s  (max %.0fms)\n", sparkline(report.Daily, 0, hi), hi*1000)
+		return
+	}
+
+	errs := make([]float64, len(report.Daily))
+	left := make([]float64, len(report.Daily))
+	hi, spent := 0.0, 0.0
+	for i, v := range report.Daily {
+		errs[i] = 1 - v
+		if !math.IsNaN(v) {
+			hi = math.Max(hi, errs[i])
+			spent += errs[i] / (report.ErrorBudget * windowDays)
+		}
+		left[i] = 1 - spent
+	}
+	fmt.Printf("  Daily errors    %s  (max %.3f%%)\n", sparkline(errs, 0, hi), hi*100)
+	fmt.Printf("  Budget left     %s  (%.0f%% → %.0f%%)\n", sparkline(left, math.Min(0, left[len(left)-1]), 1), 100.0, left[len(left)-1]*100)
+}
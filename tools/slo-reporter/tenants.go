@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// worstTenantsShown is how many tenants the -per-tenant summary lists.
+const worstTenantsShown = 5
+
+// forTenant narrows SLO definitions to one tenant's requests. Ratio SLOs
+// are built from arbitrary queries that can't be narrowed, so they are left
+// out.
+func forTenant(defs []SLODefinition, tenant string) []SLODefinition {
+	sliced := make([]SLODefinition, 0, len(defs))
+	for _, def := range defs {
+		if def.Kind == sliRatio {
+			continue
+		}
+		def.Name = fmt.Sprintf("%s [tenant %s]", def.Name, tenant)
+		def.Selector = fmt.Sprintf(`%s, tenant=%q`, def.Selector, tenant)
+		def.Tenant = tenant
+		sliced = append(sliced, def)
+	}
+	return sliced
+}
+
+// discoverTenants lists the tenants with traffic in the window across the
+// selectors of defs. The "other" bucket the API folds rare tenants into is
+// reported like any tenant.
+func discoverTenants(ctx context.Context, client *PrometheusClient, defs []SLODefinition) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, def := range defs {
+		if def.Kind == sliRatio {
+			continue
+		}
+		query := fmt.Sprintf(`sum by (tenant) (rate(codigo_http_requests_total{%s, tenant!=""}[%dd]))`, def.Selector, windowDays)
+		samples, err := client.QueryVector(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			seen[s.Labels["tenant"]] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("no tenant-labeled requests found; is METRICS_TENANT_DIMENSIONS=true set on the API?")
+	}
+	tenants := make([]string, 0, len(seen))
+	for t := range seen {
+		tenants = append(tenants, t)
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// printWorstTenants lists the tenants with the highest burn rate on any of
+// their SLOs.
+func printWorstTenants(reports []*SLOReport, n int) {
+	worst := make(map[string]*SLOReport)
+	for _, r := range reports {
+		if r.Tenant == "" {
+			continue
+		}
+		if cur, ok := worst[r.Tenant]; !ok || r.BurnRate > cur.BurnRate {
+			worst[r.Tenant] = r
+		}
+	}
+	tenants := make([]string, 0, len(worst))
+	for t := range worst {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return worst[tenants[i]].BurnRate > worst[tenants[j]].BurnRate })
+	if len(tenants) > n {
+		tenants = tenants[:n]
+	}
+
+	fmt.Println("WORST OFFENDERS")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, t := range tenants {
+		r := worst[t]
+		fmt.Printf("%-24s %-40s burn %.2fx  %s\n", t, r.SLI, r.BurnRate, statusLabel(r.Status))
+	}
+	fmt.Println(strings.Repeat("=", 80))
+}
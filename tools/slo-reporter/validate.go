@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcomes of validating one SLI query. Anything but queryOK is a dead
+// query: the report would show no data or NaN for it.
+const (
+	queryOK       = "ok"
+	queryInvalid  = "invalid"   // the store rejected the query
+	queryNoSeries = "no_series" // the selector matches no series in the window
+	queryNoData   = "no_data"   // the query runs but returns nothing usable
+)
+
+// QueryCheck is the validation result of one SLO's SLI query.
+type QueryCheck struct {
+	SLO    string `json:"slo"`
+	Kind   string `json:"kind"`
+	Query  string `json:"query"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// runValidate checks every SLI query against the metric store and exits 1
+// when any of them is dead, so a broken SLO file fails CI rather than
+// showing up as "no data" in the next report.
+func runValidate(ctx context.Context, args []string) error {
+	var opts sloOptions
+	fs := newFlagSet("validate")
+	opts.register(fs)
+	output := fs.String("output", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	client, err := opts.newClient()
+	if err != nil {
+		return err
+	}
+	definitions, err := opts.definitions(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	checks := make([]QueryCheck, 0, len(definitions))
+	dead := 0
+	for _, def := range definitions {
+		check, err := validateSLO(ctx, client, def)
+		if err != nil {
+			return fmt.Errorf("validating %s SLO: %w", def.Name, err)
+		}
+		if check.Status != queryOK {
+			dead++
+		}
+		checks = append(checks, check)
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(checks); err != nil {
+			return fmt.Errorf("encoding JSON: %w", err)
+		}
+	} else {
+		printChecks(checks)
+	}
+	if dead > 0 {
+		return fmt.Errorf("%d of %d SLI queries are dead", dead, len(checks))
+	}
+	return nil
+}
+
+// validateSLO runs def's SLI query once to see that it parses and returns
+// a value, after checking that the series it reads exist in the window.
+// Only errors talking to the store are returned; a bad query is a check.
+func validateSLO(ctx context.Context, client *PrometheusClient, def SLODefinition) (QueryCheck, error) {
+	window := fmt.Sprintf("%dd", windowDays)
+	check := QueryCheck{
+		SLO:   def.Name,
+		Kind:  def.Kind,
+		Query: strings.Join(strings.Fields(sliQuery(def, window)), " "),
+	}
+	switch def.Kind {
+	case sliAvailability, sliLatency, sliRatio:
+	default:
+		check.Status, check.Detail = queryInvalid, fmt.Sprintf("unknown SLI kind %q", def.Kind)
+		return check, nil
+	}
+
+	// A typo in a label value still parses and evaluates to an empty
+	// vector, so look for the series before running the query itself.
+	for _, selector := range sliSelectors(def, window) {
+		found, err := client.hasSeries(ctx, selector)
+		if err != nil {
+			if status, detail, ok := queryRejected(err); ok {
+				check.Status, check.Detail = status, detail
+				return check, nil
+			}
+			return check, err
+		}
+		if !found && isSeriesSelector(selector) {
+			check.Status = queryNoSeries
+			check.Detail = fmt.Sprintf("%s matches no series in the last %s", selector, window)
+			return check, nil
+		}
+		if !found {
+			check.Status = queryNoData
+			check.Detail = fmt.Sprintf("%s returned no data", strings.Join(strings.Fields(selector), " "))
+			return check, nil
+		}
+	}
+
+	samples, err := client.QueryVector(ctx, sliQuery(def, window))
+	if err != nil {
+		if status, detail, ok := queryRejected(err); ok {
+			check.Status, check.Detail = status, detail
+			return check, nil
+		}
+		return check, err
+	}
+	switch {
+	case len(samples) == 0:
+		check.Status, check.Detail = queryNoData, "query returned an empty result"
+	case math.IsNaN(samples[0].Value):
+		check.Status, check.Detail = queryNoData, "query returned NaN; no events in the window"
+	default:
+		check.Status = queryOK
+	}
+	return check, nil
+}
+
+// sliSelectors returns the series selectors def's SLI reads. Availability
+// and latency SLIs are built on known metrics; ratio SLIs are arbitrary
+// PromQL, so their good and total queries are run instead.
+func sliSelectors(def SLODefinition, rng string) []string {
+	switch def.Kind {
+	case sliAvailability:
+		return []string{fmt.Sprintf("codigo_http_requests_total{%s}", def.Selector)}
+	case sliLatency:
+		return []string{fmt.Sprintf("codigo_http_request_duration_seconds_bucket{%s}", def.Selector)}
+	default:
+		return []string{
+			strings.ReplaceAll(def.GoodQuery, "$window", rng),
+			strings.ReplaceAll(def.TotalQuery, "$window", rng),
+		}
+	}
+}
+
+// queryRejected reports whether err means the store refused the query
+// itself, as opposed to being unreachable or overloaded.
+func queryRejected(err error) (status, detail string, ok bool) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return "", "", false
+	}
+	switch apiErr.Type {
+	case "bad_data", "execution":
+		return queryInvalid, apiErr.Message, true
+	}
+	return "", "", false
+}
+
+// hasSeries reports whether selector matches any series over the SLO
+// window. Selectors that aren't plain series selectors, like the queries of
+// ratio SLIs, are run as instant queries and must return a sample.
+func (p *PrometheusClient) hasSeries(ctx context.Context, selector string) (bool, error) {
+	if !isSeriesSelector(selector) {
+		samples, err := p.QueryVector(ctx, selector)
+		return len(samples) > 0, err
+	}
+
+	end := p.at
+	if end.IsZero() {
+		end = time.Now()
+	}
+	params := url.Values{}
+	params.Add("match[]", selector)
+	params.Add("start", strconv.FormatInt(end.Add(-windowDays*24*time.Hour).Unix(), 10))
+	params.Add("end", strconv.FormatInt(end.Unix(), 10))
+	// Stores that don't know limit ignore it and list every series
+	params.Add("limit", "1")
+
+	var series []map[string]string
+	if err := p.get(ctx, "/api/v1/series", params, &series); err != nil {
+		return false, err
+	}
+	return len(series) > 0, nil
+}
+
+// isSeriesSelector reports whether query is a bare metric{matchers}
+// selector the series API accepts.
+func isSeriesSelector(query string) bool {
+	name, _, found := strings.Cut(strings.TrimSpace(query), "{")
+	if !found || !strings.HasSuffix(strings.TrimSpace(query), "}") {
+		return false
+	}
+	return !strings.ContainsAny(name, " ()[]")
+}
+
+func printChecks(checks []QueryCheck) {
+	fmt.Println("SLI Query Validation")
+	fmt.Println("====================")
+	for _, c := range checks {
+		fmt.Printf("%-10s %s (%s)\n", strings.ToUpper(c.Status), c.SLO, c.Kind)
+		if c.Status != queryOK {
+			fmt.Printf("           query:  %s\n", c.Query)
+			fmt.Printf("           reason: %s\n", c.Detail)
+		}
+	}
+}